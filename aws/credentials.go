@@ -2,47 +2,101 @@ package aws
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"janus/logger"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awsretry "github.com/aws/aws-sdk-go-v2/aws/retry"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
+	ststypes "github.com/aws/aws-sdk-go-v2/service/sts/types"
+	"github.com/aws/smithy-go"
 
-	"janus/gcp"
+	"janus/retry"
 	"janus/types"
 )
 
-// GetCredentials retrieves temporary AWS credentials using GCP identity token
-func GetCredentials(ctx context.Context, stsRegion, awsAssumeRoleArn, sessionIdentifier string, gcpTokenRetriever gcp.CustomIdentityTokenRetriever) (*types.AWSTempCredentials, error) {
-	logger.Logger.Debug("Creating AWS STS configuration for region", "StsRegion", stsRegion)
-	assumeRoleCfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(stsRegion))
+// GetCredentials retrieves temporary AWS credentials using an identity token from tokenRetriever
+func GetCredentials(ctx context.Context, cfg types.Config, sessionIdentifier string, tokenRetriever stscreds.IdentityTokenRetriever) (*types.AWSTempCredentials, error) {
+	logger.Logger.Debug("Creating AWS STS configuration for region", "StsRegion", cfg.STSRegion, "stsEndpoint", cfg.STSEndpoint, "fips", cfg.UseFIPS, "dualStack", cfg.UseDualStack)
+	assumeRoleCfg, err := config.LoadDefaultConfig(ctx, stsConfigOptions(cfg)...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load AWS config: %w", err)
 	}
 
 	stsAssumeClient := sts.NewFromConfig(assumeRoleCfg)
-	logger.Logger.Debug("Creating AWS STS client", "roleArn", awsAssumeRoleArn, "StsRegion", stsRegion)
-	awsCredsCache := aws.NewCredentialsCache(
-		stscreds.NewWebIdentityRoleProvider(
-			stsAssumeClient,
-			awsAssumeRoleArn,
-			gcpTokenRetriever,
-			func(o *stscreds.WebIdentityRoleOptions) {
-				o.RoleSessionName = sessionIdentifier
-			},
-		),
+	logger.Logger.Debug("Creating AWS STS client", "roleArn", cfg.RoleArn, "StsRegion", cfg.STSRegion)
+	var provider aws.CredentialsProvider = stscreds.NewWebIdentityRoleProvider(
+		stsAssumeClient,
+		cfg.RoleArn,
+		tokenRetriever,
+		func(o *stscreds.WebIdentityRoleOptions) {
+			o.RoleSessionName = sessionIdentifier
+			o.Duration = cfg.Duration
+			// Session policies restrict the final credentials, so with role chaining
+			// they are applied to the last hop instead
+			if len(cfg.Chain) == 0 {
+				o.Policy = sessionPolicy(cfg.Policy)
+				o.PolicyARNs = policyDescriptors(cfg.PolicyARNs)
+			}
+		},
 	)
 
-	logger.Logger.Debug("Retrieving AWS credentials", "sessionIdentifier", sessionIdentifier)
+	// Each chained role is assumed with credentials of the previous hop
+	for i, hop := range cfg.Chain {
+		hopSessionName := hop.SessionName
+		if hopSessionName == "" {
+			hopSessionName = sessionIdentifier
+		}
+		lastHop := i == len(cfg.Chain)-1
+
+		logger.Logger.Debug("Creating AWS STS client for chained role", "roleArn", hop.RoleArn, "hop", i+1, "sessionIdentifier", hopSessionName)
+		hopClient := sts.NewFromConfig(assumeRoleCfg, func(o *sts.Options) {
+			o.Credentials = aws.NewCredentialsCache(provider)
+		})
+		provider = stscreds.NewAssumeRoleProvider(
+			hopClient,
+			hop.RoleArn,
+			func(o *stscreds.AssumeRoleOptions) {
+				o.RoleSessionName = hopSessionName
+				o.Duration = hop.Duration
+				if hop.ExternalID != "" {
+					o.ExternalID = aws.String(hop.ExternalID)
+				}
+				// The source identity of the first hop persists through later hops, its tags
+				// only when transitive
+				if i == 0 {
+					o.SourceIdentity = sourceIdentity(cfg.SourceIdentity)
+					o.Tags = sessionTags(cfg.Tags)
+					o.TransitiveTagKeys = cfg.TransitiveTagKeys
+				}
+				if lastHop {
+					o.Policy = sessionPolicy(cfg.Policy)
+					o.PolicyARNs = policyDescriptors(cfg.PolicyARNs)
+				}
+			},
+		)
+	}
+	awsCredsCache := aws.NewCredentialsCache(provider)
+
+	logger.Logger.Debug("Retrieving AWS credentials", "sessionIdentifier", sessionIdentifier, "duration", cfg.Duration)
 	awsCredentials, err := awsCredsCache.Retrieve(ctx)
 	if err != nil {
+		if isDurationRejected(err) {
+			return nil, fmt.Errorf("STS rejected requested session duration, it must not exceed the MaxSessionDuration of the role (or 1h for chained roles): %w", err)
+		}
+		if isPackedPolicyTooLarge(err) {
+			return nil, fmt.Errorf("STS rejected session policies as too large after packing, reduce the inline policy or number of policy ARNs: %w", err)
+		}
 		return nil, fmt.Errorf("failed to retrieve AWS credentials: %w", err)
 	}
 
-	logger.Logger.Debug("Successfully retrieved AWS credentials", "roleArn", awsAssumeRoleArn, "StsRegion", stsRegion, "sessionIdentifier", sessionIdentifier)
+	logger.Logger.Debug("Successfully retrieved AWS credentials", "roleArn", cfg.RoleArn, "chainedRoles", len(cfg.Chain), "StsRegion", cfg.STSRegion, "sessionIdentifier", sessionIdentifier)
 	return &types.AWSTempCredentials{
 		Version:         1,
 		AccessKeyId:     awsCredentials.AccessKeyID,
@@ -51,3 +105,103 @@ func GetCredentials(ctx context.Context, stsRegion, awsAssumeRoleArn, sessionIde
 		Expiration:      awsCredentials.Expires,
 	}, nil
 }
+
+// sessionPolicy returns the inline session policy, or nil when none is configured
+func sessionPolicy(policy string) *string {
+	if policy == "" {
+		return nil
+	}
+	return aws.String(policy)
+}
+
+// policyDescriptors converts managed policy ARNs into STS policy descriptors
+func policyDescriptors(policyArns []string) []ststypes.PolicyDescriptorType {
+	if len(policyArns) == 0 {
+		return nil
+	}
+	descriptors := make([]ststypes.PolicyDescriptorType, 0, len(policyArns))
+	for _, arn := range policyArns {
+		descriptors = append(descriptors, ststypes.PolicyDescriptorType{Arn: aws.String(arn)})
+	}
+	return descriptors
+}
+
+// stsConfigOptions returns the options of the AWS configuration used for STS requests. A custom
+// endpoint replaces endpoint resolution, otherwise the regional endpoint is resolved honoring the
+// FIPS and dual-stack settings.
+func stsConfigOptions(cfg types.Config) []func(*config.LoadOptions) error {
+	options := []func(*config.LoadOptions) error{
+		config.WithRegion(cfg.STSRegion),
+		config.WithRetryer(func() aws.Retryer {
+			return stsRetryer(retry.NewPolicy(cfg.Retries, cfg.RetryDelay))
+		}),
+	}
+	if cfg.STSEndpoint != "" {
+		options = append(options, config.WithBaseEndpoint(cfg.STSEndpoint))
+	}
+	if cfg.UseFIPS {
+		options = append(options, config.WithUseFIPSEndpoint(aws.FIPSEndpointStateEnabled))
+	}
+	if cfg.UseDualStack {
+		options = append(options, config.WithUseDualStackEndpoint(aws.DualStackEndpointStateEnabled))
+	}
+	return options
+}
+
+// sourceIdentity returns the source identity, or nil when none is configured
+func sourceIdentity(sourceIdentity string) *string {
+	if sourceIdentity == "" {
+		return nil
+	}
+	return aws.String(sourceIdentity)
+}
+
+// sessionTags converts session tags into STS tags
+func sessionTags(tags []types.SessionTag) []ststypes.Tag {
+	if len(tags) == 0 {
+		return nil
+	}
+	stsTags := make([]ststypes.Tag, 0, len(tags))
+	for _, tag := range tags {
+		stsTags = append(stsTags, ststypes.Tag{Key: aws.String(tag.Key), Value: aws.String(tag.Value)})
+	}
+	return stsTags
+}
+
+// isDurationRejected reports whether STS refused the request because of the requested
+// DurationSeconds, typically because it exceeds the role's MaxSessionDuration
+func isDurationRejected(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.ErrorCode() == "ValidationError" && strings.Contains(apiErr.ErrorMessage(), "DurationSeconds")
+}
+
+// isPackedPolicyTooLarge reports whether STS refused the request because the compressed
+// session policies and tags exceed the packed size limit
+func isPackedPolicyTooLarge(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.ErrorCode() == "PackedPolicyTooLarge"
+}
+
+// stsRetryer returns the SDK standard retryer, which retries throttling, server and connection
+// errors, using the backoff of the retry policy. STS additionally reports failures to reach
+// the identity provider's keys as IDPCommunicationError, which are retried as well.
+func stsRetryer(policy retry.Policy) aws.Retryer {
+	return awsretry.NewStandard(func(o *awsretry.StandardOptions) {
+		o.MaxAttempts = policy.Retries + 1
+		o.MaxBackoff = policy.MaxDelay
+		o.Backoff = awsretry.BackoffDelayerFunc(func(attempt int, _ error) (time.Duration, error) {
+			return policy.Delay(attempt), nil
+		})
+		o.Retryables = append(o.Retryables, awsretry.RetryableErrorCode{
+			Codes: map[string]struct{}{
+				(*ststypes.IDPCommunicationErrorException)(nil).ErrorCode(): {},
+			},
+		})
+	})
+}
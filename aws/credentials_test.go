@@ -0,0 +1,168 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/stretchr/testify/assert"
+
+	"janus/logger"
+	"janus/types"
+)
+
+func init() {
+	logger.InitLogger("ERROR")
+}
+
+// staticTokenRetriever returns the same identity token on every call
+type staticTokenRetriever string
+
+func (s staticTokenRetriever) GetIdentityToken() ([]byte, error) {
+	return []byte(s), nil
+}
+
+// newSTSServer starts an STS stand-in answering AssumeRoleWithWebIdentity and AssumeRole
+// and returns the form of every request it received
+func newSTSServer(t *testing.T) (*httptest.Server, func() []url.Values) {
+	var mu sync.Mutex
+	var requests []url.Values
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		requests = append(requests, r.PostForm)
+		count := len(requests)
+		mu.Unlock()
+
+		action := r.PostForm.Get("Action")
+		w.Header().Set("Content-Type", "text/xml")
+		fmt.Fprintf(w, `<%[1]sResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <%[1]sResult>
+    <Credentials>
+      <AccessKeyId>ASIA%[2]d</AccessKeyId>
+      <SecretAccessKey>mock-secret-access-key</SecretAccessKey>
+      <SessionToken>mock-session-token</SessionToken>
+      <Expiration>2099-01-01T00:00:00Z</Expiration>
+    </Credentials>
+  </%[1]sResult>
+</%[1]sResponse>`, action, count)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() []url.Values {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]url.Values(nil), requests...)
+	}
+}
+
+// isolateAWSConfig keeps the shared AWS configuration and environment of the machine running
+// the tests from affecting STS requests
+func isolateAWSConfig(t *testing.T) {
+	for _, name := range []string{"AWS_ENDPOINT_URL", "AWS_ENDPOINT_URL_STS", "AWS_USE_FIPS_ENDPOINT", "AWS_USE_DUALSTACK_ENDPOINT", "AWS_PROFILE"} {
+		t.Setenv(name, "")
+	}
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+	t.Setenv("AWS_CONFIG_FILE", os.DevNull)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", os.DevNull)
+}
+
+func TestSTSConfigOptionsEndpoint(t *testing.T) {
+	isolateAWSConfig(t)
+
+	tests := []struct {
+		name string
+		cfg  types.Config
+		want string
+	}{
+		{"regional", types.Config{STSRegion: "eu-west-1"}, "https://sts.eu-west-1.amazonaws.com/"},
+		{"FIPS", types.Config{STSRegion: "us-east-2", UseFIPS: true}, "https://sts-fips.us-east-2.amazonaws.com/"},
+		{"dual-stack", types.Config{STSRegion: "eu-west-1", UseDualStack: true}, "https://sts.eu-west-1.api.aws/"},
+		{"custom endpoint", types.Config{STSRegion: "eu-west-1", STSEndpoint: "https://vpce-0123-abcd.sts.eu-west-1.vpce.amazonaws.com"}, "https://vpce-0123-abcd.sts.eu-west-1.vpce.amazonaws.com/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			awsCfg, err := config.LoadDefaultConfig(ctx, stsConfigOptions(tt.cfg)...)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			// Capture the request URL instead of sending the request
+			var got string
+			client := sts.NewFromConfig(awsCfg, func(o *sts.Options) {
+				o.HTTPClient = smithyhttp.ClientDoFunc(func(r *http.Request) (*http.Response, error) {
+					got = r.URL.String()
+					return nil, errors.New("request captured")
+				})
+			})
+			_, _ = client.AssumeRoleWithWebIdentity(ctx, &sts.AssumeRoleWithWebIdentityInput{
+				RoleArn:          aws.String("arn:aws:iam::123456789012:role/landing"),
+				RoleSessionName:  aws.String("janus"),
+				WebIdentityToken: aws.String("token"),
+			})
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestGetCredentialsChainedSessionSettings(t *testing.T) {
+	server, requests := newSTSServer(t)
+	isolateAWSConfig(t)
+
+	cfg := types.Config{
+		RoleArn:     "arn:aws:iam::123456789012:role/landing",
+		STSRegion:   types.STSRegionDefault,
+		STSEndpoint: server.URL,
+		Chain: []types.RoleHop{
+			{RoleArn: "arn:aws:iam::123456789012:role/tagged"},
+			{RoleArn: "arn:aws:iam::123456789012:role/workload"},
+		},
+		SourceIdentity:    "aws-access@my-project.iam.gserviceaccount.com",
+		Tags:              []types.SessionTag{{Key: "team", Value: "platform"}, {Key: "project", Value: "my-project"}},
+		TransitiveTagKeys: []string{"project"},
+		RetryDelay:        time.Millisecond,
+	}
+
+	credentials, err := GetCredentials(context.Background(), cfg, "janus", staticTokenRetriever("token"))
+	assert.NoError(t, err)
+	assert.Equal(t, "ASIA3", credentials.AccessKeyId)
+
+	forms := requests()
+	if !assert.Len(t, forms, 3) {
+		return
+	}
+
+	assert.Equal(t, "AssumeRoleWithWebIdentity", forms[0].Get("Action"))
+	assert.Empty(t, forms[0].Get("Tags.member.1.Key"), "web identity request must not carry tags")
+	assert.Empty(t, forms[0].Get("SourceIdentity"), "web identity request must not carry a source identity")
+
+	assert.Equal(t, "AssumeRole", forms[1].Get("Action"))
+	assert.Equal(t, "arn:aws:iam::123456789012:role/tagged", forms[1].Get("RoleArn"))
+	assert.Equal(t, "aws-access@my-project.iam.gserviceaccount.com", forms[1].Get("SourceIdentity"))
+	assert.Equal(t, "team", forms[1].Get("Tags.member.1.Key"))
+	assert.Equal(t, "platform", forms[1].Get("Tags.member.1.Value"))
+	assert.Equal(t, "project", forms[1].Get("Tags.member.2.Key"))
+	assert.Equal(t, "my-project", forms[1].Get("Tags.member.2.Value"))
+	assert.Equal(t, "project", forms[1].Get("TransitiveTagKeys.member.1"))
+
+	assert.Equal(t, "AssumeRole", forms[2].Get("Action"))
+	assert.Empty(t, forms[2].Get("Tags.member.1.Key"), "transitive tags are carried by STS, not sent again")
+	assert.Empty(t, forms[2].Get("SourceIdentity"), "the source identity is carried by STS, not sent again")
+}
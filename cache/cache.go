@@ -0,0 +1,139 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"janus/filelock"
+	"janus/types"
+)
+
+const (
+	dirName       = "janus-go"
+	dirPerm       = 0o700
+	fileExtension = ".json"
+	lockExtension = ".lock"
+)
+
+// Cache stores temporary AWS credentials on disk so that repeated credential_process
+// invocations can reuse them until they are about to expire
+type Cache struct {
+	// Dir is the directory holding cached credential files
+	Dir string
+	// RefreshWindow is how long before expiration cached credentials are considered stale
+	RefreshWindow time.Duration
+}
+
+// DefaultDir returns the default cache directory located under the user cache directory
+func DefaultDir() (string, error) {
+	userCacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("couldn't determine user cache directory: %w", err)
+	}
+	return filepath.Join(userCacheDir, dirName), nil
+}
+
+// New creates a credential cache in the given directory, creating the directory if needed
+func New(dir string, refreshWindow time.Duration) (*Cache, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("cache directory cannot be empty")
+	}
+	if refreshWindow < 0 {
+		return nil, fmt.Errorf("cache refresh window cannot be negative: %s", refreshWindow)
+	}
+	if err := os.MkdirAll(dir, dirPerm); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory %s: %w", dir, err)
+	}
+	// An existing directory may have been created with looser permissions
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check cache directory %s: %w", dir, err)
+	}
+	if info.Mode().Perm()&^dirPerm != 0 {
+		if err := os.Chmod(dir, dirPerm); err != nil {
+			return nil, fmt.Errorf("failed to restrict permissions of cache directory %s: %w", dir, err)
+		}
+	}
+	return &Cache{
+		Dir:           dir,
+		RefreshWindow: refreshWindow,
+	}, nil
+}
+
+// Key derives a cache key from the parameters which identify a set of credentials
+func Key(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// Lock acquires an exclusive lock for the given key, waiting until it is available or ctx is done.
+// The lock serializes concurrent invocations so that only one of them refreshes credentials.
+func (c *Cache) Lock(ctx context.Context, key string) (*filelock.Lock, error) {
+	return filelock.Acquire(ctx, c.path(key, lockExtension))
+}
+
+// Get returns cached credentials for the key if they exist and are not within the refresh window
+func (c *Cache) Get(key string, now time.Time) (*types.AWSTempCredentials, error) {
+	data, err := os.ReadFile(c.path(key, fileExtension))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read cached credentials: %w", err)
+	}
+
+	var credentials types.AWSTempCredentials
+	if err := json.Unmarshal(data, &credentials); err != nil {
+		return nil, fmt.Errorf("failed to parse cached credentials: %w", err)
+	}
+
+	if !now.Add(c.RefreshWindow).Before(credentials.Expiration) {
+		return nil, nil
+	}
+	return &credentials, nil
+}
+
+// Put stores credentials for the key. The file is written with owner-only permissions
+// and atomically renamed into place so readers never observe a partial write.
+func (c *Cache) Put(key string, credentials *types.AWSTempCredentials) error {
+	data, err := json.Marshal(credentials)
+	if err != nil {
+		return fmt.Errorf("failed to encode credentials: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(c.Dir, key+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary cache file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to set cache file permissions: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close cache file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), c.path(key, fileExtension)); err != nil {
+		return fmt.Errorf("failed to store cache file: %w", err)
+	}
+	return nil
+}
+
+func (c *Cache) path(key, extension string) string {
+	return filepath.Join(c.Dir, key+extension)
+}
@@ -0,0 +1,146 @@
+package cache
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"janus/types"
+)
+
+func TestKey(t *testing.T) {
+	key := Key("arn:aws:iam::123456789012:role/MyRole", "us-east-1", "session", "gcp")
+
+	assert.Len(t, key, 64, "Key should be a hex encoded SHA-256 digest")
+	assert.Equal(t, key, Key("arn:aws:iam::123456789012:role/MyRole", "us-east-1", "session", "gcp"), "Key should be stable")
+	assert.NotEqual(t, key, Key("arn:aws:iam::123456789012:role/MyRole", "us-east-1", "session", "other"), "Key should depend on every part")
+	assert.NotEqual(t, Key("ab", "c"), Key("a", "bc"), "Key parts should not be ambiguous")
+}
+
+func TestCacheRoundTrip(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "cache")
+	c, err := New(dir, 15*time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+
+	now := time.Now()
+	key := Key("role", "region")
+
+	cached, err := c.Get(key, now)
+	assert.NoError(t, err)
+	assert.Nil(t, cached, "Empty cache should not return credentials")
+
+	credentials := &types.AWSTempCredentials{
+		Version:         1,
+		AccessKeyId:     "access_key",
+		SecretAccessKey: "secret_key",
+		SessionToken:    "session_token",
+		Expiration:      now.Add(time.Hour).UTC(),
+	}
+	if err := c.Put(key, credentials); err != nil {
+		t.Fatalf("Failed to store credentials: %v", err)
+	}
+
+	info, err := os.Stat(filepath.Join(dir, key+fileExtension))
+	if err != nil {
+		t.Fatalf("Cache file not written: %v", err)
+	}
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm(), "Cache file should only be accessible by owner")
+
+	cached, err = c.Get(key, now)
+	assert.NoError(t, err)
+	if assert.NotNil(t, cached, "Fresh credentials should be returned from cache") {
+		assert.Equal(t, credentials.AccessKeyId, cached.AccessKeyId)
+		assert.True(t, credentials.Expiration.Equal(cached.Expiration), "Expiration mismatch")
+	}
+
+	cached, err = c.Get(key, now.Add(50*time.Minute))
+	assert.NoError(t, err)
+	assert.Nil(t, cached, "Credentials within refresh window should not be returned")
+}
+
+func TestCacheCorruptEntry(t *testing.T) {
+	dir := t.TempDir()
+	c, err := New(dir, 0)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+
+	key := Key("corrupt")
+	if err := os.WriteFile(filepath.Join(dir, key+fileExtension), []byte("{not json"), 0o600); err != nil {
+		t.Fatalf("Failed to write corrupt entry: %v", err)
+	}
+
+	cached, err := c.Get(key, time.Now())
+	assert.Error(t, err)
+	assert.Nil(t, cached)
+}
+
+func TestCacheLock(t *testing.T) {
+	c, err := New(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+
+	lock, err := c.Lock(context.Background(), Key("locked"))
+	if err != nil {
+		t.Fatalf("Failed to acquire lock: %v", err)
+	}
+
+	acquired := make(chan struct{})
+	go func() {
+		second, err := c.Lock(context.Background(), Key("locked"))
+		if err == nil {
+			second.Unlock()
+		}
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("Second lock acquired while first lock is held")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	assert.NoError(t, lock.Unlock())
+
+	select {
+	case <-acquired:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Second lock not acquired after first lock was released")
+	}
+}
+
+func TestNewRestrictsPermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Permissions are not enforced on Windows")
+	}
+	dir := filepath.Join(t.TempDir(), "cache")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	if _, err := New(dir, time.Minute); err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		t.Fatalf("Failed to stat cache directory: %v", err)
+	}
+	assert.Equal(t, os.FileMode(0o700), info.Mode().Perm(), "Existing cache directory should only be accessible by owner")
+}
+
+func TestNewInvalid(t *testing.T) {
+	_, err := New("", time.Minute)
+	assert.Error(t, err, "Empty directory should be rejected")
+
+	_, err = New(t.TempDir(), -time.Minute)
+	assert.Error(t, err, "Negative refresh window should be rejected")
+}
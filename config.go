@@ -0,0 +1,133 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"sort"
+
+	"janus/profile"
+	"janus/types"
+)
+
+// applyProfile applies settings of the profile selected with -profile to flags which were
+// not set explicitly on the command line, so that flags override configuration file values
+func applyProfile(fs *flag.FlagSet, opts *options) error {
+	if *opts.profile == "" {
+		return nil
+	}
+
+	path := *opts.configFile
+	if path == "" {
+		path = os.Getenv(types.EnvConfigFile)
+	}
+	if path == "" {
+		defaultPath, err := profile.DefaultPath()
+		if err != nil {
+			return err
+		}
+		path = defaultPath
+	}
+
+	file, err := profile.Load(path)
+	if err != nil {
+		return err
+	}
+	p, err := file.Profile(*opts.profile)
+	if err != nil {
+		return err
+	}
+
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	applyString := func(name string, target *string, value string) {
+		if target != nil && value != "" && !set[name] {
+			*target = value
+		}
+	}
+
+	applyString("rolearn", opts.awsAssumeRoleArn, p.RoleArn)
+	applyString("stsregion", opts.stsRegion, p.STSRegion)
+	applyString("stsendpoint", opts.stsEndpoint, p.STSEndpoint)
+	applyString("sessionid", opts.sessionId, p.SessionID)
+	applyString("sessiontemplate", opts.sessionTemplate, p.SessionTemplate)
+	applyString("audience", opts.audience, p.Audience)
+	applyString("provider", opts.provider, p.Provider)
+	applyString("tokenfile", opts.tokenFile, p.TokenFile)
+	applyString("impersonate", opts.impersonate, p.Impersonate)
+	applyString("policy", opts.policy, p.Policy)
+	applyString("sourceidentity", opts.sourceIdentity, p.SourceIdentity)
+	applyString("output", opts.outputFormat, p.Output)
+	applyString("cachedir", opts.cacheDir, p.CacheDir)
+
+	if p.Duration != 0 && !set["duration"] {
+		*opts.duration = p.Duration
+	}
+	if p.CacheRefresh != 0 && !set["cacherefresh"] {
+		*opts.cacheRefreshWindow = p.CacheRefresh
+	}
+	if p.Retries != nil && !set["retries"] {
+		*opts.retries = *p.Retries
+	}
+	if p.RetryDelay != 0 && !set["retrydelay"] {
+		*opts.retryDelay = p.RetryDelay
+	}
+	if p.Timeout != 0 && !set["timeout"] {
+		*opts.timeout = p.Timeout
+	}
+	if p.FIPS != nil && !set["fips"] {
+		*opts.useFIPS = *p.FIPS
+	}
+	if p.DualStack != nil && !set["dualstack"] {
+		*opts.useDualStack = *p.DualStack
+	}
+	if p.Cache != nil && !set["cache"] {
+		*opts.useCache = *p.Cache
+	}
+	if len(p.Delegates) > 0 && !set["delegate"] {
+		opts.delegates = p.Delegates
+	}
+	if len(p.PolicyARNs) > 0 && !set["policyarn"] {
+		opts.policyArns = p.PolicyARNs
+	}
+	if len(p.Tags) > 0 && !set["tag"] {
+		opts.tags = make(sessionTagFlag, 0, len(p.Tags))
+		for _, key := range sortedKeys(p.Tags) {
+			opts.tags = append(opts.tags, types.SessionTag{Key: key, Value: p.Tags[key]})
+		}
+	}
+	if len(p.MetadataTags) > 0 && !set["metadatatag"] {
+		opts.metadataTags = make(metadataTagFlag, 0, len(p.MetadataTags))
+		for _, key := range sortedKeys(p.MetadataTags) {
+			opts.metadataTags = append(opts.metadataTags, types.MetadataTag{Key: key, Source: p.MetadataTags[key]})
+		}
+	}
+	if len(p.TransitiveTags) > 0 && !set["transitivetag"] {
+		opts.transitiveTags = p.TransitiveTags
+	}
+	if len(p.Chain) > 0 && !set["chain"] {
+		opts.roleChain = make(roleChainFlag, 0, len(p.Chain))
+		for _, hop := range p.Chain {
+			opts.roleChain = append(opts.roleChain, types.RoleHop{
+				RoleArn:     hop.RoleArn,
+				SessionName: hop.SessionName,
+				ExternalID:  hop.ExternalID,
+				Duration:    hop.Duration,
+			})
+		}
+	}
+
+	return nil
+}
+
+// sortedKeys returns the keys of a profile map in a stable order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+
+	"janus/logger"
+	"janus/output"
+	"janus/types"
+)
+
+const (
+	exitCommandNotStarted = 127 // Exit code when the child command cannot be started, as used by shells
+)
+
+// forwardedSignals are relayed to the child process
+var forwardedSignals = []os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT}
+
+// terminalSignals are sent by the terminal to its whole foreground process group
+var terminalSignals = []os.Signal{os.Interrupt, syscall.SIGQUIT}
+
+// runExec runs a command with credentials injected into its environment and exits with the
+// command's exit code
+func runExec(args []string) {
+	fs, opts := newFlagSet(os.Args[0] + " " + commandExec)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s [flags] -- command [args...]\n", fs.Name())
+		fs.PrintDefaults()
+	}
+	config := parseConfig(fs, opts, args)
+
+	command := fs.Args()
+	if len(command) == 0 {
+		exitWithUsage(fs, errors.New("command to execute is required"))
+	}
+
+	ctx := context.Background()
+
+	config, sessionIdentifier := resolveSession(ctx, config)
+
+	credentials, err := fetchCredentials(ctx, config, sessionIdentifier)
+	if err != nil {
+		exitWithError(err)
+	}
+
+	os.Exit(runChild(command, childEnvironment(os.Environ(), credentials, config.STSRegion)))
+}
+
+// childEnvironment returns the environment with existing AWS credentials replaced by the given
+// credentials. The region is set to the STS region unless the environment already defines one.
+func childEnvironment(environ []string, credentials *types.AWSTempCredentials, region string) []string {
+	injected := output.Environment(credentials)
+	replaced := make(map[string]bool, len(injected)+1)
+	for _, variable := range injected {
+		name, _, _ := strings.Cut(variable, "=")
+		replaced[name] = true
+	}
+	// Legacy name for the session token still read by some tools
+	replaced[types.EnvSecurityToken] = true
+
+	env := make([]string, 0, len(environ)+len(injected)+2)
+	hasRegion := false
+	for _, variable := range environ {
+		name, value, _ := strings.Cut(variable, "=")
+		if replaced[name] {
+			continue
+		}
+		if (name == types.EnvRegion || name == types.EnvDefaultRegion) && value != "" {
+			hasRegion = true
+		}
+		env = append(env, variable)
+	}
+	env = append(env, injected...)
+
+	if !hasRegion {
+		env = append(env, types.EnvRegion+"="+region, types.EnvDefaultRegion+"="+region)
+	}
+	return env
+}
+
+// runChild runs the command with the given environment, forwarding signals to it, and returns
+// its exit code. A command terminated by a signal returns 128 plus the signal number. Signals
+// typed at the terminal are not forwarded while the command shares the terminal's foreground
+// process group, as it already received them and tools like terraform exit immediately on a
+// second interrupt.
+func runChild(command []string, env []string) int {
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Env = env
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, forwardedSignals...)
+	defer signal.Stop(signals)
+
+	if err := cmd.Start(); err != nil {
+		logger.Logger.Error(fmt.Errorf("failed to start command: %w", err).Error())
+		return exitCommandNotStarted
+	}
+
+	foreground := inForegroundProcessGroup()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				if foreground && slices.Contains(terminalSignals, sig) {
+					logger.Logger.Debug("Not forwarding signal received by command from the terminal", "signal", sig)
+					continue
+				}
+				logger.Logger.Debug("Forwarding signal to command", "signal", sig)
+				_ = cmd.Process.Signal(sig)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	err := cmd.Wait()
+	if err == nil {
+		return 0
+	}
+
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		logger.Logger.Error(fmt.Errorf("failed to run command: %w", err).Error())
+		return 1
+	}
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return exitErr.ExitCode()
+}
@@ -0,0 +1,87 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+)
+
+// openPTY opens a new pseudo terminal and returns its master and slave ends
+func openPTY() (*os.File, *os.File, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	fd := int(master.Fd())
+	if err := unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err != nil {
+		master.Close()
+		return nil, nil, fmt.Errorf("failed to unlock pseudo terminal: %w", err)
+	}
+	number, err := unix.IoctlGetUint32(fd, unix.TIOCGPTN)
+	if err != nil {
+		master.Close()
+		return nil, nil, fmt.Errorf("failed to get pseudo terminal number: %w", err)
+	}
+	slave, err := os.OpenFile(fmt.Sprintf("/dev/pts/%d", number), os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, nil, err
+	}
+	return master, slave, nil
+}
+
+// TestRunChildTerminalInterrupt verifies that an interrupt typed at the terminal reaches the
+// command once when janus-go runs in the terminal's foreground process group
+func TestRunChildTerminalInterrupt(t *testing.T) {
+	master, slave, err := openPTY()
+	if err != nil {
+		t.Skipf("Pseudo terminal not available: %v", err)
+	}
+	defer master.Close()
+	defer slave.Close()
+
+	// Run janus-go as session leader of the terminal, so that it and its child form the
+	// terminal's foreground process group
+	countFile := filepath.Join(t.TempDir(), "count")
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), envRunChild+"="+countFile)
+	cmd.Stdin = slave
+	cmd.Stdout = slave
+	cmd.Stderr = slave
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Failed to start janus-go: %v", err)
+	}
+	defer func() { _ = cmd.Process.Kill() }()
+
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(countFile + ".ready")
+		return err == nil
+	}, 10*time.Second, 10*time.Millisecond, "Child should start")
+
+	// Type Ctrl-C, which the terminal sends to the whole foreground process group
+	if _, err := master.Write([]byte{0x03}); err != nil {
+		t.Fatalf("Failed to write to pseudo terminal: %v", err)
+	}
+	assert.NoError(t, cmd.Wait())
+
+	// A forwarded interrupt arriving while the first is pending merges with it, so check
+	// that janus-go did not forward it as well as counting what the child received
+	slave.Close()
+	output, _ := io.ReadAll(master)
+	assert.NotContains(t, string(output), "Forwarding signal to command", "Interrupt from the terminal should not be forwarded")
+
+	count, err := os.ReadFile(countFile)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", string(count), "Child should receive the interrupt only once")
+}
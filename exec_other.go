@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+// Process groups are only available on unix platforms, elsewhere every signal is forwarded
+func inForegroundProcessGroup() bool {
+	return false
+}
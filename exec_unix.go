@@ -0,0 +1,26 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// inForegroundProcessGroup reports whether janus-go runs in the foreground process group of
+// its controlling terminal. The child command is started in the same process group, so it
+// receives signals typed at the terminal directly.
+func inForegroundProcessGroup() bool {
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		return false
+	}
+	defer tty.Close()
+
+	pgrp, err := unix.IoctlGetInt(int(tty.Fd()), unix.TIOCGPGRP)
+	if err != nil {
+		return false
+	}
+	return pgrp == unix.Getpgrp()
+}
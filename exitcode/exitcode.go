@@ -0,0 +1,152 @@
+// Package exitcode classifies failures so that wrapper scripts can tell them apart by exit code
+package exitcode
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/aws/smithy-go"
+
+	"janus/logger"
+	"janus/retry"
+)
+
+// Class is a category of failure with its own exit code
+type Class string
+
+const (
+	ClassError        Class = "error"         // Unclassified failure
+	ClassUsage        Class = "usage"         // Invalid flags, configuration or command
+	ClassIdentity     Class = "identity"      // No identity token could be obtained, e.g. not running on GCP
+	ClassAccessDenied Class = "access_denied" // The role trust policy rejected the identity
+	ClassInvalidToken Class = "invalid_token" // STS rejected the identity token as invalid or expired
+	ClassThrottled    Class = "throttled"     // STS throttled the request
+	ClassUnavailable  Class = "unavailable"   // A service could not be reached or the deadline passed
+	ClassRejected     Class = "rejected"      // STS rejected the request parameters
+)
+
+// codes maps every class to its exit code. 2 matches the exit code of flag parsing errors.
+var codes = map[Class]int{
+	ClassError:        1,
+	ClassUsage:        2,
+	ClassIdentity:     3,
+	ClassAccessDenied: 4,
+	ClassInvalidToken: 5,
+	ClassThrottled:    6,
+	ClassUnavailable:  7,
+	ClassRejected:     8,
+}
+
+// hints suggest how to resolve failures of each class
+var hints = map[Class]string{
+	ClassUsage:        "Check the command line flags and the configuration profile.",
+	ClassIdentity:     "Run on GCE or GKE with workload identity, set GOOGLE_APPLICATION_CREDENTIALS, or select another identity provider with -provider.",
+	ClassAccessDenied: "Check that the trust policy of the role allows sts:AssumeRoleWithWebIdentity for the audience and subject of the identity token.",
+	ClassInvalidToken: "Check that the token issuer is configured as an IAM OIDC identity provider and that the token audience and expiry are valid.",
+	ClassThrottled:    "STS is throttling requests, retry later or reuse credentials with -cache.",
+	ClassUnavailable:  "Retry later, or increase -retries and -timeout.",
+	ClassRejected:     "Check the requested session duration, session policies and STS region.",
+}
+
+// stsErrorClasses maps STS API error codes to classes
+var stsErrorClasses = map[string]Class{
+	"AccessDenied":            ClassAccessDenied,
+	"IDPRejectedClaim":        ClassAccessDenied,
+	"InvalidIdentityToken":    ClassInvalidToken,
+	"ExpiredTokenException":   ClassInvalidToken,
+	"ExpiredToken":            ClassInvalidToken,
+	"Throttling":              ClassThrottled,
+	"ThrottlingException":     ClassThrottled,
+	"RequestLimitExceeded":    ClassThrottled,
+	"IDPCommunicationError":   ClassUnavailable,
+	"ServiceUnavailable":      ClassUnavailable,
+	"InternalFailure":         ClassUnavailable,
+	"ValidationError":         ClassRejected,
+	"MalformedPolicyDocument": ClassRejected,
+	"PackedPolicyTooLarge":    ClassRejected,
+	"RegionDisabledException": ClassRejected,
+}
+
+// Error is a failure of a known class
+type Error struct {
+	Class Class
+	Err   error
+}
+
+// Error returns the message of the underlying error
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Wrap marks err as a failure of the given class
+func Wrap(class Class, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Class: class, Err: err}
+}
+
+// Classify returns the class of err. STS API error codes and transient failures take precedence
+// over classes set with Wrap, so that an identity token rejected by STS or a metadata server that
+// is briefly unavailable is not reported as a missing identity.
+func Classify(err error) Class {
+	if err == nil {
+		return ClassError
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		if class, ok := stsErrorClasses[apiErr.ErrorCode()]; ok {
+			return class
+		}
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) || retry.IsTransient(err) {
+		return ClassUnavailable
+	}
+
+	var classified *Error
+	if errors.As(err, &classified) {
+		return classified.Class
+	}
+	return ClassError
+}
+
+// Code returns the exit code of a class
+func Code(class Class) int {
+	if code, ok := codes[class]; ok {
+		return code
+	}
+	return codes[ClassError]
+}
+
+// Hint returns a suggestion how to resolve failures of a class, or an empty string
+func Hint(class Class) string {
+	return hints[class]
+}
+
+// report is the JSON representation of a failure
+type report struct {
+	Code  int    `json:"code"`
+	Class Class  `json:"class"`
+	Error string `json:"error"`
+	Hint  string `json:"hint,omitempty"`
+}
+
+// WriteJSON writes err as a JSON object with its exit code, class and hint, with secrets redacted
+func WriteJSON(w io.Writer, err error) error {
+	class := Classify(err)
+	return json.NewEncoder(w).Encode(report{
+		Code:  Code(class),
+		Class: class,
+		Error: logger.Redact(err.Error()),
+		Hint:  Hint(class),
+	})
+}
@@ -0,0 +1,70 @@
+package exitcode
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	ststypes "github.com/aws/aws-sdk-go-v2/service/sts/types"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+
+	"janus/retry"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want Class
+	}{
+		{"unclassified", errors.New("failed to encode credentials"), ClassError},
+		{"wrapped class", fmt.Errorf("failed: %w", Wrap(ClassUsage, errors.New("invalid role ARN"))), ClassUsage},
+		{"access denied", fmt.Errorf("failed to retrieve AWS credentials: %w", &smithy.GenericAPIError{Code: "AccessDenied"}), ClassAccessDenied},
+		{"invalid token", &ststypes.InvalidIdentityTokenException{}, ClassInvalidToken},
+		{"expired token", &ststypes.ExpiredTokenException{}, ClassInvalidToken},
+		{"throttled", &smithy.GenericAPIError{Code: "Throttling"}, ClassThrottled},
+		{"idp unreachable", &ststypes.IDPCommunicationErrorException{}, ClassUnavailable},
+		{"duration rejected", &smithy.GenericAPIError{Code: "ValidationError"}, ClassRejected},
+		{"sts code wins over wrapped class", Wrap(ClassIdentity, &smithy.GenericAPIError{Code: "InvalidIdentityToken"}), ClassInvalidToken},
+		{"unknown sts code", &smithy.GenericAPIError{Code: "SomethingNew"}, ClassError},
+		{"transient", &retry.StatusError{StatusCode: http.StatusServiceUnavailable}, ClassUnavailable},
+		{"deadline", fmt.Errorf("fetch: %w", context.DeadlineExceeded), ClassUnavailable},
+		{"transient wins over wrapped class", Wrap(ClassIdentity, &retry.StatusError{StatusCode: http.StatusBadGateway}), ClassUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Classify(tt.err))
+		})
+	}
+}
+
+func TestCodesAreDistinct(t *testing.T) {
+	seen := map[int]Class{}
+	for class, code := range codes {
+		assert.NotContains(t, seen, code, "classes %s and %s share exit code %d", class, seen[code], code)
+		seen[code] = class
+		if class != ClassError {
+			assert.NotEmpty(t, Hint(class), "class %s has no hint", class)
+		}
+	}
+	assert.Equal(t, 1, Code("unknown"))
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	err := fmt.Errorf("failed to retrieve AWS credentials: %w", &smithy.GenericAPIError{Code: "AccessDenied", Message: "Not authorized"})
+	assert.NoError(t, WriteJSON(&buf, err))
+
+	var got map[string]any
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &got))
+	assert.Equal(t, float64(4), got["code"])
+	assert.Equal(t, "access_denied", got["class"])
+	assert.Contains(t, got["error"], "Not authorized")
+	assert.NotEmpty(t, got["hint"])
+}
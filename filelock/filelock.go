@@ -0,0 +1,52 @@
+// Package filelock serializes processes with exclusive locks on lock files
+package filelock
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+)
+
+// pollInterval is how often a lock held by another process is retried
+var pollInterval = 50 * time.Millisecond
+
+// Lock is an exclusive lock held on a lock file
+type Lock struct {
+	file *os.File
+}
+
+// Acquire takes an exclusive lock on the lock file at path, creating it if needed and waiting
+// until the lock is available or ctx is done
+func Acquire(ctx context.Context, path string) (*Lock, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+	for {
+		locked, err := tryLockFile(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to lock file: %w", err)
+		}
+		if locked {
+			return &Lock{file: f}, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			f.Close()
+			return nil, fmt.Errorf("timed out waiting for lock: %w", ctx.Err())
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// Unlock releases the lock
+func (l *Lock) Unlock() error {
+	if err := unlockFile(l.file); err != nil {
+		l.file.Close()
+		return fmt.Errorf("failed to unlock file: %w", err)
+	}
+	return l.file.Close()
+}
@@ -0,0 +1,60 @@
+package filelock
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAcquire(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file.lock")
+
+	lock, err := Acquire(context.Background(), path)
+	if err != nil {
+		t.Fatalf("Failed to acquire lock: %v", err)
+	}
+
+	acquired := make(chan struct{})
+	go func() {
+		second, err := Acquire(context.Background(), path)
+		if err == nil {
+			second.Unlock()
+		}
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("Second lock acquired while first lock is held")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	assert.NoError(t, lock.Unlock())
+
+	select {
+	case <-acquired:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Second lock not acquired after first lock was released")
+	}
+}
+
+func TestAcquireTimeout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file.lock")
+
+	lock, err := Acquire(context.Background(), path)
+	if err != nil {
+		t.Fatalf("Failed to acquire lock: %v", err)
+	}
+	defer lock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err = Acquire(ctx, path)
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "Waiting for a held lock should stop at the deadline")
+	assert.Less(t, time.Since(start), 2*time.Second)
+}
@@ -0,0 +1,15 @@
+//go:build !unix
+
+package filelock
+
+import "os"
+
+// File locking is only implemented on unix platforms, elsewhere concurrent
+// invocations may refresh the same cache entry independently.
+func tryLockFile(_ *os.File) (bool, error) {
+	return true, nil
+}
+
+func unlockFile(_ *os.File) error {
+	return nil
+}
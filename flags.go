@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"janus/types"
+)
+
+// stringSliceFlag is a flag.Value collecting every occurrence of a repeatable flag
+type stringSliceFlag []string
+
+func (s *stringSliceFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringSliceFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// roleChainFlag is a flag.Value collecting chained roles given as
+// ARN[,sessionname=NAME][,externalid=ID][,duration=DURATION]
+type roleChainFlag []types.RoleHop
+
+func (c *roleChainFlag) String() string {
+	arns := make([]string, 0, len(*c))
+	for _, hop := range *c {
+		arns = append(arns, hop.RoleArn)
+	}
+	return strings.Join(arns, ",")
+}
+
+func (c *roleChainFlag) Set(value string) error {
+	hop, err := parseRoleHop(value)
+	if err != nil {
+		return err
+	}
+	*c = append(*c, hop)
+	return nil
+}
+
+// parseRoleHop parses a chained role specification into a role hop
+func parseRoleHop(value string) (types.RoleHop, error) {
+	fields := strings.Split(value, ",")
+	hop := types.RoleHop{RoleArn: strings.TrimSpace(fields[0])}
+
+	for _, field := range fields[1:] {
+		key, val, ok := strings.Cut(field, "=")
+		if !ok {
+			return types.RoleHop{}, fmt.Errorf("invalid role chain option %q (expected key=value)", field)
+		}
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "sessionname":
+			hop.SessionName = val
+		case "externalid":
+			hop.ExternalID = val
+		case "duration":
+			duration, err := time.ParseDuration(val)
+			if err != nil {
+				return types.RoleHop{}, fmt.Errorf("invalid role chain duration %q: %w", val, err)
+			}
+			hop.Duration = duration
+		default:
+			return types.RoleHop{}, fmt.Errorf("unknown role chain option %q (expected sessionname, externalid or duration)", key)
+		}
+	}
+
+	return hop, nil
+}
+
+// sessionTagFlag is a flag.Value collecting session tags given as KEY=VALUE
+type sessionTagFlag []types.SessionTag
+
+func (s *sessionTagFlag) String() string {
+	tags := make([]string, 0, len(*s))
+	for _, tag := range *s {
+		tags = append(tags, tag.Key+"="+tag.Value)
+	}
+	return strings.Join(tags, ",")
+}
+
+func (s *sessionTagFlag) Set(value string) error {
+	key, val, ok := strings.Cut(value, "=")
+	if !ok {
+		return fmt.Errorf("invalid session tag %q (expected key=value)", value)
+	}
+	*s = append(*s, types.SessionTag{Key: key, Value: val})
+	return nil
+}
+
+// metadataTagFlag is a flag.Value collecting session tags given as KEY=SOURCE whose values
+// are looked up from GCP metadata or Kubernetes
+type metadataTagFlag []types.MetadataTag
+
+func (m *metadataTagFlag) String() string {
+	tags := make([]string, 0, len(*m))
+	for _, tag := range *m {
+		tags = append(tags, tag.Key+"="+tag.Source)
+	}
+	return strings.Join(tags, ",")
+}
+
+func (m *metadataTagFlag) Set(value string) error {
+	key, source, ok := strings.Cut(value, "=")
+	if !ok {
+		return fmt.Errorf("invalid metadata session tag %q (expected key=source)", value)
+	}
+	*m = append(*m, types.MetadataTag{Key: key, Source: source})
+	return nil
+}
+
+// loadPolicy returns the session policy given either inline or as @file, compacted so that
+// its size matches what is sent to STS
+func loadPolicy(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	policy := []byte(value)
+	if path, ok := strings.CutPrefix(value, "@"); ok {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read session policy file: %w", err)
+		}
+		policy = data
+	}
+
+	var compacted bytes.Buffer
+	if err := json.Compact(&compacted, policy); err != nil {
+		return "", fmt.Errorf("invalid session policy JSON: %w", err)
+	}
+	return compacted.String(), nil
+}
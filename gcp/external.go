@@ -0,0 +1,62 @@
+package gcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"golang.org/x/oauth2/google"
+
+	"janus/logger"
+)
+
+// generateExternalAccountIdentityToken mints an identity token from Workload Identity Federation
+// (external_account) credentials. The external credential is exchanged for a federated token
+// through Google STS, which then calls generateIdToken for the service account named in the
+// credential configuration's service_account_impersonation_url.
+func generateExternalAccountIdentityToken(ctx context.Context, credentialsJSON []byte, audience string) (string, error) {
+	serviceAccount, federatedJSON, err := externalAccountServiceAccount(credentialsJSON)
+	if err != nil {
+		return "", err
+	}
+
+	creds, err := google.CredentialsFromJSONWithType(ctx, federatedJSON, google.ExternalAccount, cloudPlatformScope)
+	if err != nil {
+		return "", fmt.Errorf("failed to load external account credentials: %w", err)
+	}
+
+	logger.Logger.Debug("Generating identity token from external account credentials", "serviceAccount", serviceAccount)
+	return impersonatedIdentityToken(ctx, creds.TokenSource, serviceAccount, nil, audience)
+}
+
+// externalAccountServiceAccount returns the service account impersonated by an external_account
+// credential configuration, together with the configuration stripped of the impersonation URL
+// so that the federated token itself is used to mint the identity token
+func externalAccountServiceAccount(credentialsJSON []byte) (string, []byte, error) {
+	var config map[string]any
+	if err := json.Unmarshal(credentialsJSON, &config); err != nil {
+		return "", nil, fmt.Errorf("failed to parse external account credentials: %w", err)
+	}
+
+	impersonationURL, _ := config["service_account_impersonation_url"].(string)
+	if impersonationURL == "" {
+		return "", nil, fmt.Errorf("external account credentials have no service_account_impersonation_url, use -impersonate to name the service account")
+	}
+
+	// https://iamcredentials.googleapis.com/v1/projects/-/serviceAccounts/EMAIL:generateAccessToken
+	_, resource, found := strings.Cut(impersonationURL, "/serviceAccounts/")
+	serviceAccount, _, _ := strings.Cut(resource, ":")
+	if !found || serviceAccount == "" {
+		return "", nil, fmt.Errorf("failed to parse service account from service_account_impersonation_url: %s", impersonationURL)
+	}
+
+	delete(config, "service_account_impersonation_url")
+	delete(config, "service_account_impersonation")
+	federatedJSON, err := json.Marshal(config)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to encode external account credentials: %w", err)
+	}
+
+	return serviceAccount, federatedJSON, nil
+}
@@ -0,0 +1,72 @@
+package gcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExternalAccountServiceAccount(t *testing.T) {
+	credentialsJSON := []byte(`{
+		"type": "external_account",
+		"audience": "//iam.googleapis.com/projects/123/locations/global/workloadIdentityPools/pool/providers/provider",
+		"service_account_impersonation_url": "https://iamcredentials.googleapis.com/v1/projects/-/serviceAccounts/wif@my-project.iam.gserviceaccount.com:generateAccessToken"
+	}`)
+
+	serviceAccount, federatedJSON, err := externalAccountServiceAccount(credentialsJSON)
+	assert.NoError(t, err)
+	assert.Equal(t, "wif@my-project.iam.gserviceaccount.com", serviceAccount)
+	assert.NotContains(t, string(federatedJSON), "service_account_impersonation_url")
+	assert.Contains(t, string(federatedJSON), "workloadIdentityPools")
+
+	_, _, err = externalAccountServiceAccount([]byte(`{"type": "external_account"}`))
+	assert.ErrorContains(t, err, "-impersonate")
+}
+
+func TestGenerateExternalAccountIdentityToken(t *testing.T) {
+	var gotSubjectToken, gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/token":
+			_ = r.ParseForm()
+			gotSubjectToken = r.PostForm.Get("subject_token")
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"access_token":"federated-access-token","issued_token_type":"urn:ietf:params:oauth:token-type:access_token","token_type":"Bearer","expires_in":3600}`))
+		case "/v1/projects/-/serviceAccounts/wif@my-project.iam.gserviceaccount.com:generateIdToken":
+			gotAuth = r.Header.Get("Authorization")
+			_, _ = w.Write([]byte(`{"token":"wif-id-token"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	defer func(endpoint string) { iamCredentialsEndpoint = endpoint }(iamCredentialsEndpoint)
+	iamCredentialsEndpoint = server.URL
+
+	subjectTokenFile := filepath.Join(t.TempDir(), "token")
+	assert.NoError(t, os.WriteFile(subjectTokenFile, []byte("external-subject-token"), 0600))
+
+	credentialsJSON, err := json.Marshal(map[string]any{
+		"type":                              "external_account",
+		"audience":                          "//iam.googleapis.com/projects/123/locations/global/workloadIdentityPools/pool/providers/provider",
+		"subject_token_type":                "urn:ietf:params:oauth:token-type:jwt",
+		"token_url":                         server.URL + "/v1/token",
+		"service_account_impersonation_url": fmt.Sprintf("%s/v1/projects/-/serviceAccounts/wif@my-project.iam.gserviceaccount.com:generateAccessToken", server.URL),
+		"credential_source":                 map[string]any{"file": subjectTokenFile},
+	})
+	assert.NoError(t, err)
+
+	token, err := generateExternalAccountIdentityToken(context.Background(), credentialsJSON, "gcp")
+	assert.NoError(t, err)
+	assert.Equal(t, "wif-id-token", token)
+	assert.Equal(t, "external-subject-token", gotSubjectToken)
+	assert.Equal(t, "Bearer federated-access-token", gotAuth)
+}
@@ -0,0 +1,105 @@
+package gcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+
+	"janus/logger"
+	"janus/retry"
+)
+
+const (
+	cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+)
+
+// iamCredentialsEndpoint is the base URL of the IAM Service Account Credentials API
+var iamCredentialsEndpoint = "https://iamcredentials.googleapis.com"
+
+// generateIdTokenRequest is the request body of the IAM Credentials generateIdToken method
+type generateIdTokenRequest struct {
+	Audience     string   `json:"audience"`
+	IncludeEmail bool     `json:"includeEmail"`
+	Delegates    []string `json:"delegates,omitempty"`
+}
+
+// generateImpersonatedIdentityToken mints an identity token for the target service account,
+// authenticating with application default credentials
+func generateImpersonatedIdentityToken(ctx context.Context, targetServiceAccount string, delegates []string, audience string) (string, error) {
+	creds, err := google.FindDefaultCredentials(ctx, cloudPlatformScope)
+	if err != nil {
+		return "", fmt.Errorf("failed to get default credentials: %w", err)
+	}
+
+	return impersonatedIdentityToken(ctx, creds.TokenSource, targetServiceAccount, delegates, audience)
+}
+
+// impersonatedIdentityToken calls the IAM Credentials generateIdToken API for the target service
+// account using an access token from the source token source. Each delegate in the chain must
+// hold the Service Account Token Creator role on the next one.
+func impersonatedIdentityToken(ctx context.Context, source oauth2.TokenSource, targetServiceAccount string, delegates []string, audience string) (string, error) {
+	accessToken, err := source.Token()
+	if err != nil {
+		return "", fmt.Errorf("failed to get access token for impersonation: %w", err)
+	}
+
+	request := generateIdTokenRequest{
+		Audience:     audience,
+		IncludeEmail: true,
+	}
+	for _, delegate := range delegates {
+		request.Delegates = append(request.Delegates, serviceAccountResource(delegate))
+	}
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode generateIdToken request: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/v1/%s:generateIdToken", iamCredentialsEndpoint, serviceAccountResource(url.PathEscape(targetServiceAccount)))
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return "", fmt.Errorf("failed to create generateIdToken request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	accessToken.SetAuthHeader(req)
+
+	logger.Logger.Debug("Generating identity token by impersonating service account", "serviceAccount", targetServiceAccount, "delegates", delegates)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to execute generateIdToken request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read generateIdToken response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("generateIdToken request for %s failed with %w", targetServiceAccount, &retry.StatusError{StatusCode: resp.StatusCode, Body: string(body)})
+	}
+
+	var tokenResp struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(body, &tokenResp); err != nil {
+		return "", fmt.Errorf("failed to parse generateIdToken response: %w", err)
+	}
+	if tokenResp.Token == "" {
+		return "", fmt.Errorf("generateIdToken response for %s contained no token", targetServiceAccount)
+	}
+
+	return tokenResp.Token, nil
+}
+
+// serviceAccountResource returns the IAM resource name of a service account
+func serviceAccountResource(serviceAccount string) string {
+	return "projects/-/serviceAccounts/" + serviceAccount
+}
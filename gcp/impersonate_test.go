@@ -0,0 +1,67 @@
+package gcp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+
+	"janus/logger"
+)
+
+func init() {
+	// Initialize logger for tests
+	logger.InitLogger("ERROR")
+}
+
+func TestImpersonatedIdentityToken(t *testing.T) {
+	var gotPath, gotAuth string
+	var gotRequest generateIdTokenRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotAuth = r.Header.Get("Authorization")
+		_ = json.NewDecoder(r.Body).Decode(&gotRequest)
+		_, _ = w.Write([]byte(`{"token":"impersonated-id-token"}`))
+	}))
+	defer server.Close()
+
+	defer func(endpoint string) { iamCredentialsEndpoint = endpoint }(iamCredentialsEndpoint)
+	iamCredentialsEndpoint = server.URL
+
+	source := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "caller-access-token"})
+	token, err := impersonatedIdentityToken(context.Background(), source,
+		"target@my-project.iam.gserviceaccount.com",
+		[]string{"delegate@my-project.iam.gserviceaccount.com"},
+		"gcp")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "impersonated-id-token", token)
+	assert.Equal(t, "/v1/projects/-/serviceAccounts/target@my-project.iam.gserviceaccount.com:generateIdToken", gotPath)
+	assert.Equal(t, "Bearer caller-access-token", gotAuth)
+	assert.Equal(t, generateIdTokenRequest{
+		Audience:     "gcp",
+		IncludeEmail: true,
+		Delegates:    []string{"projects/-/serviceAccounts/delegate@my-project.iam.gserviceaccount.com"},
+	}, gotRequest)
+}
+
+func TestImpersonatedIdentityTokenPermissionDenied(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":{"code":403,"status":"PERMISSION_DENIED"}}`, http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	defer func(endpoint string) { iamCredentialsEndpoint = endpoint }(iamCredentialsEndpoint)
+	iamCredentialsEndpoint = server.URL
+
+	source := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "caller-access-token"})
+	_, err := impersonatedIdentityToken(context.Background(), source, "target@my-project.iam.gserviceaccount.com", nil, "gcp")
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "status 403")
+	assert.Contains(t, err.Error(), "PERMISSION_DENIED")
+}
@@ -3,15 +3,19 @@ package gcp
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	"janus/kubernetes"
 	"janus/logger"
+	"janus/retry"
 
 	"cloud.google.com/go/compute/metadata"
 	"golang.org/x/oauth2"
@@ -22,10 +26,10 @@ import (
 )
 
 const (
-	defaultAudience        = types.GCPTokenAudience
 	googleCloudSDKAudience = "32555940559.apps.googleusercontent.com"
-	googleTokenInfoURL     = "https://oauth2.googleapis.com/token"
 	metadataClientTimeout  = 3 * time.Second // Timeout for GCP metadata client requests
+
+	identityTokenExpiryDelta = time.Minute // Identity tokens are fetched again this long before they expire
 )
 
 // credentialsFile represents the structure of the credentials JSON file
@@ -40,9 +44,29 @@ type credentialsFile struct {
 	ClientEmail  string `json:"client_email"`
 }
 
+// googleTokenURL is the OAuth token endpoint refresh tokens of user credentials are exchanged at
+var googleTokenURL = "https://oauth2.googleapis.com/token"
+
+// productNameFile holds the product name reported by the machine firmware, which names Google
+// on GCE instances and GKE nodes
+var productNameFile = "/sys/class/dmi/id/product_name"
+
+// detectMetadataServer probes for the GCP metadata server without caching the answer
+var detectMetadataServer = func(ctx context.Context, c *metadata.Client) bool {
+	return c.OnGCEWithContext(ctx)
+}
+
+// errMetadataNotDetected is returned by a detection attempt which found no metadata server
+var errMetadataNotDetected = errors.New("GCP metadata server not detected")
+
 // MetadataClient wraps the standard metadata.Client
 type MetadataClient struct {
 	*metadata.Client
+	// Retry is applied to requests failing with transient errors when running on GCE, where
+	// the metadata server may briefly be unavailable, for example while a GKE node starts
+	Retry retry.Policy
+
+	onGCE atomic.Bool
 }
 
 // NewMetadataClient creates a new GCP metadata client
@@ -61,7 +85,13 @@ func (c *MetadataClient) ProjectIDWithContext(ctx context.Context) (string, erro
 	if err := ctx.Err(); err != nil {
 		return "", err
 	}
-	return c.Client.ProjectIDWithContext(ctx)
+
+	var projectID string
+	err := c.withRetry(ctx, func() (err error) {
+		projectID, err = c.Client.ProjectIDWithContext(ctx)
+		return err
+	})
+	return projectID, err
 }
 
 // HostnameWithContext gets the hostname with context awareness
@@ -69,20 +99,113 @@ func (c *MetadataClient) HostnameWithContext(ctx context.Context) (string, error
 	if err := ctx.Err(); err != nil {
 		return "", err
 	}
-	return c.Client.HostnameWithContext(ctx)
+
+	var hostname string
+	err := c.withRetry(ctx, func() (err error) {
+		hostname, err = c.Client.HostnameWithContext(ctx)
+		return err
+	})
+	return hostname, err
+}
+
+// DefaultEmailWithContext gets the email of the default service account of the instance with context awareness
+func (c *MetadataClient) DefaultEmailWithContext(ctx context.Context) (string, error) {
+	return c.getWithRetry(ctx, func(ctx context.Context) (string, error) {
+		return c.Client.EmailWithContext(ctx, "default")
+	})
+}
+
+// ZoneWithContext gets the zone of the instance with context awareness
+func (c *MetadataClient) ZoneWithContext(ctx context.Context) (string, error) {
+	return c.getWithRetry(ctx, c.Client.ZoneWithContext)
+}
+
+// InstanceNameWithContext gets the name of the instance with context awareness
+func (c *MetadataClient) InstanceNameWithContext(ctx context.Context) (string, error) {
+	return c.getWithRetry(ctx, c.Client.InstanceNameWithContext)
+}
+
+// ClusterNameWithContext gets the name of the GKE cluster the instance belongs to with context awareness
+func (c *MetadataClient) ClusterNameWithContext(ctx context.Context) (string, error) {
+	return c.getWithRetry(ctx, func(ctx context.Context) (string, error) {
+		return c.Client.InstanceAttributeValueWithContext(ctx, "cluster-name")
+	})
+}
+
+// getWithRetry returns the value of a metadata lookup, retried like every other request of the client
+func (c *MetadataClient) getWithRetry(ctx context.Context, get func(context.Context) (string, error)) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	var value string
+	err := c.withRetry(ctx, func() (err error) {
+		value, err = get(ctx)
+		return err
+	})
+	return value, err
+}
+
+// withRetry calls fn with the retry policy of the client when running on GCE. Elsewhere there
+// is no metadata server to wait for and fn is called once.
+func (c *MetadataClient) withRetry(ctx context.Context, fn func() error) error {
+	if !c.OnGCE(ctx) {
+		return fn()
+	}
+	return retry.Do(ctx, c.Retry, retry.IsTransient, fn)
+}
+
+// OnGCE reports whether janus-go runs on GCP. Unlike metadata.OnGCE a negative answer is not
+// cached for the life of the process, and on machines whose firmware names Google the
+// detection is retried with the policy of the client, as the GKE metadata server may not
+// answer yet while a node starts. Once detected the metadata server is assumed to stay.
+func (c *MetadataClient) OnGCE(ctx context.Context) bool {
+	if c.onGCE.Load() {
+		return true
+	}
+
+	policy := c.Retry
+	if !googleMachine() {
+		policy.Retries = 0
+	}
+	err := retry.Do(ctx, policy, func(err error) bool {
+		return errors.Is(err, errMetadataNotDetected)
+	}, func() error {
+		if !detectMetadataServer(ctx, c.Client) {
+			return errMetadataNotDetected
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Logger.Debug("Not running on GCP", "error", err)
+		return false
+	}
+
+	c.onGCE.Store(true)
+	return true
+}
+
+// googleMachine reports whether the machine firmware names Google as the product
+func googleMachine() bool {
+	productName, err := os.ReadFile(productNameFile)
+	if err != nil {
+		return false
+	}
+	return strings.Contains(string(productName), "Google")
 }
 
 // GetSessionIdentifier retrieves session identifier from command line flag, environment variable,
-// or generates it from GCP metadata (in that order of precedence)
-func GetSessionIdentifier(ctx context.Context, sessionIdFlag string, gcpMetadataClient *MetadataClient) (string, error) {
+// session name template, or generates it from the Kubernetes pod or GCP metadata (in that order
+// of precedence). GCP metadata is skipped when gcpMetadataClient is nil.
+func GetSessionIdentifier(ctx context.Context, config types.Config, gcpMetadataClient *MetadataClient) (string, error) {
 	// First check context state
 	if err := ctx.Err(); err != nil {
 		return "", err
 	}
 
 	// Check if provided via command line flag
-	if sessionIdFlag != "" {
-		return sessionIdFlag, nil
+	if config.SessionID != "" {
+		return config.SessionID, nil
 	}
 
 	// Check if provided via environment variable
@@ -90,26 +213,52 @@ func GetSessionIdentifier(ctx context.Context, sessionIdFlag string, gcpMetadata
 		return envSessionId, nil
 	}
 
-	// Try creating it from GCP metadata
-	logger.Logger.Debug("Attempting to create session identifier from GCP metadata")
-
-	// Check context again before making metadata requests
-	if err := ctx.Err(); err != nil {
-		return "", err
+	// An explicit template must render, there is no fallback
+	if config.SessionNameTemplate != "" {
+		sessionId, err := RenderSessionName(config.SessionNameTemplate, NewTemplateData(ctx, config, gcpMetadataClient))
+		if err != nil {
+			return "", fmt.Errorf("couldn't determine session identifier: %w", err)
+		}
+		logger.Logger.Debug("Using session identifier rendered from template", "sessionIdentifier", sessionId)
+		return sessionId, nil
 	}
 
-	sessionId, err := CreateSessionIdentifier(ctx, gcpMetadataClient)
-	if err == nil {
-		return sessionId, nil
+	// On GKE the metadata server reports the node hostname, which all pods of a node share
+	if kubernetes.Detected() {
+		sessionId, err := RenderSessionName(types.SessionNameTemplateKubernetes, NewTemplateData(ctx, config, gcpMetadataClient))
+		if err == nil {
+			logger.Logger.Debug("Using Kubernetes pod as session identifier", "sessionIdentifier", sessionId)
+			return sessionId, nil
+		}
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		logger.Logger.Debug("Failed to create session identifier from Kubernetes pod, falling back to GCP metadata", "error", err)
 	}
 
-	// Check context before falling back
-	if err := ctx.Err(); err != nil {
-		return "", err
+	if gcpMetadataClient != nil {
+		// Try creating it from GCP metadata
+		logger.Logger.Debug("Attempting to create session identifier from GCP metadata")
+
+		// Check context again before making metadata requests
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+
+		sessionId, err := CreateSessionIdentifier(ctx, gcpMetadataClient)
+		if err == nil {
+			return sessionId, nil
+		}
+
+		// Check context before falling back
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+
+		// Fall back to local hostname if GCP metadata fails
+		logger.Logger.Debug("Failed to create session identifier from GCP metadata, falling back to OS hostname", "error", err)
 	}
 
-	// Fall back to local hostname if GCP metadata fails
-	logger.Logger.Debug("Failed to create session identifier from GCP metadata, falling back to OS hostname", "error", err)
 	hostname, err := os.Hostname()
 	if err != nil {
 		return "", fmt.Errorf("couldn't determine session identifier: %w", err)
@@ -117,11 +266,12 @@ func GetSessionIdentifier(ctx context.Context, sessionIdFlag string, gcpMetadata
 
 	// Use hostname as fallback
 	logger.Logger.Debug("Using local hostname as session identifier", "hostname", hostname)
-	return hostname, nil
+	return SanitizeSessionName(hostname), nil
 }
 
 // CreateSessionIdentifier constructs AWS session identifier from GCP metadata information.
-// This implementation uses concatenation of GCP project ID and machine hostname.
+// This implementation uses concatenation of GCP project ID and machine hostname, made a valid
+// role session name with SanitizeSessionName.
 func CreateSessionIdentifier(ctx context.Context, c *MetadataClient) (string, error) {
 	projectID, err := c.ProjectIDWithContext(ctx)
 	if err != nil {
@@ -133,76 +283,98 @@ func CreateSessionIdentifier(ctx context.Context, c *MetadataClient) (string, er
 		return "", fmt.Errorf("couldn't fetch Hostname from GCP metadata server: %w", err)
 	}
 
-	s := fmt.Sprintf("%s-%s", projectID, hostname)
-	if len(s) > 32 {
-		s = s[:32]
-	}
-	return s, nil
+	return SanitizeSessionName(fmt.Sprintf("%s-%s", projectID, hostname)), nil
 }
 
-// printIdentityTokenIfEnabled prints the identity token if enabled in config and log level is DEBUG
+// printIdentityTokenIfEnabled prints the claims of the identity token if enabled in config and
+// log level is DEBUG. The token itself is never logged.
 func printIdentityTokenIfEnabled(config types.Config, tokenSource oauth2.TokenSource) {
 	if config.PrintIdToken && config.LogLevel == "DEBUG" {
 		if token, err := tokenSource.Token(); err != nil {
 			logger.Logger.Error(fmt.Errorf("failed to get identity token for printing: %w", err).Error())
 		} else {
-			logger.Logger.Debug("Google identity token", "id_token", token.AccessToken)
+			logger.Logger.Debug("Google identity token", "claims", logger.JWTClaims(token.AccessToken))
 		}
 	}
 }
 
-// TokenSource returns an OAuth2 token source for authenticating with GCP.
-// It first tries to get a token from GCE metadata if running on GCP,
-// then falls back to local credentials if not on GCP.
-func TokenSource(ctx context.Context, config types.Config) (oauth2.TokenSource, error) {
-	// First try GCE metadata if running on GCP
-	if metadata.OnGCE() {
-		token, err := fetchInstanceIdentityToken(ctx)
-		if err == nil {
-			tokenSource := oauth2.StaticTokenSource(&oauth2.Token{
-				AccessToken: token,
-			})
-			printIdentityTokenIfEnabled(config, tokenSource)
-			return tokenSource, nil
-		}
-		// Log the error but continue to try other methods
-		logger.Logger.Debug("Failed to get GCE instance token", "error", err)
-	}
-
-	// Try generating token from local credentials
-	token, err := generateIdentityToken(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get identity token: %w", err)
+// TokenSource returns an OAuth2 token source of identity tokens for authenticating with GCP.
+// Tokens are reused until shortly before the expiry in their exp claim and then fetched again,
+// first from GCE metadata if running on GCP, then from local credentials. Every fetch uses ctx,
+// so it must live as long as the token source is used. Tokens are requested for the given
+// audience.
+func TokenSource(ctx context.Context, config types.Config, audience string) (oauth2.TokenSource, error) {
+	tokenSource := oauth2.ReuseTokenSourceWithExpiry(nil, identityTokenSource{ctx: ctx, config: config, audience: audience}, identityTokenExpiryDelta)
+
+	// Fetch the first token right away so that failures are reported to the caller
+	if _, err := tokenSource.Token(); err != nil {
+		return nil, err
 	}
-
-	tokenSource := oauth2.StaticTokenSource(&oauth2.Token{
-		AccessToken: token,
-	})
 	printIdentityTokenIfEnabled(config, tokenSource)
 	return tokenSource, nil
 }
 
-// CustomIdentityTokenRetriever implements the identity token retrieval functionality
-type CustomIdentityTokenRetriever struct {
-	TokenSource oauth2.TokenSource
+// identityTokenSource fetches a new Google identity token on every call
+type identityTokenSource struct {
+	ctx      context.Context
+	config   types.Config
+	audience string
 }
 
-// GetIdentityToken retrieves the identity token from the token source
-func (obj CustomIdentityTokenRetriever) GetIdentityToken() ([]byte, error) {
-	token, err := obj.TokenSource.Token()
+// Token fetches an identity token and sets its expiry from the exp claim
+func (s identityTokenSource) Token() (*oauth2.Token, error) {
+	token, err := fetchIdentityToken(s.ctx, s.config, s.audience)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get identity token: %w", err)
+	}
+
+	claims, err := types.ParseJWTClaims(token)
 	if err != nil {
-		return nil, fmt.Errorf("couldn't retrieve identity token: %w", err)
+		return nil, err
+	}
+	expiry := claims.ExpiresAt()
+	if expiry.IsZero() {
+		return nil, fmt.Errorf("identity token has no exp claim")
 	}
-	return []byte(token.AccessToken), nil
+	logger.Logger.Debug("Fetched Google identity token", "expiry", expiry)
+
+	return &oauth2.Token{
+		AccessToken: token,
+		Expiry:      expiry,
+	}, nil
 }
 
-// fetchInstanceIdentityToken retrieves an identity token from GCE metadata
-func fetchInstanceIdentityToken(ctx context.Context) (string, error) {
-	audience := os.Getenv("IDENTITY_TOKEN_AUDIENCE")
-	if audience == "" {
-		audience = defaultAudience
+// fetchIdentityToken fetches an identity token from GCE metadata if running on GCP, falling
+// back to local credentials. Impersonation needs an identity token for another service
+// account, so the instance token is skipped. Transient failures of either are retried.
+func fetchIdentityToken(ctx context.Context, config types.Config, audience string) (string, error) {
+	policy := retry.NewPolicy(config.Retries, config.RetryDelay)
+	var token string
+
+	client := NewMetadataClient(ctx)
+	client.Retry = policy
+	if config.Impersonate == "" && client.OnGCE(ctx) {
+		err := retry.Do(ctx, policy, retry.IsTransient, func() (err error) {
+			token, err = fetchInstanceIdentityToken(ctx, audience)
+			return err
+		})
+		if err == nil {
+			return token, nil
+		}
+		// Log the error but continue to try other methods
+		logger.Logger.Debug("Failed to get GCE instance token", "error", err)
 	}
 
+	// Try generating token from local credentials
+	err := retry.Do(ctx, policy, retry.IsTransient, func() (err error) {
+		token, err = generateIdentityToken(ctx, config, audience)
+		return err
+	})
+	return token, err
+}
+
+// fetchInstanceIdentityToken retrieves an identity token from GCE metadata
+func fetchInstanceIdentityToken(ctx context.Context, audience string) (string, error) {
 	// Use the built-in Google SDK function to get an identity token
 	idTokenSource, err := idtoken.NewTokenSource(ctx, audience)
 	if err != nil {
@@ -219,10 +391,10 @@ func fetchInstanceIdentityToken(ctx context.Context) (string, error) {
 }
 
 // generateIdentityToken generates an identity token from local credentials
-func generateIdentityToken(ctx context.Context) (string, error) {
-	audience := os.Getenv("IDENTITY_TOKEN_AUDIENCE")
-	if audience == "" {
-		audience = defaultAudience
+func generateIdentityToken(ctx context.Context, config types.Config, audience string) (string, error) {
+	// Impersonate the target service account using any type of default credentials
+	if config.Impersonate != "" {
+		return generateImpersonatedIdentityToken(ctx, config.Impersonate, config.Delegates, audience)
 	}
 
 	creds, err := google.FindDefaultCredentials(ctx)
@@ -238,6 +410,12 @@ func generateIdentityToken(ctx context.Context) (string, error) {
 
 	// Handle authorized user credentials
 	if cf.Type == "authorized_user" {
+		// Tokens minted from user credentials are always issued for the Cloud SDK client ID
+		if audience != types.GCPTokenAudience {
+			logger.Logger.Warn("Ignoring the configured audience, identity tokens from user credentials are issued for the Google Cloud SDK; use -impersonate to request a token with this audience",
+				"audience", audience, "tokenAudience", googleCloudSDKAudience)
+		}
+
 		// Exchange refresh token for ID token
 		data := url.Values{}
 		data.Set("client_id", cf.ClientID)
@@ -246,7 +424,7 @@ func generateIdentityToken(ctx context.Context) (string, error) {
 		data.Set("grant_type", "refresh_token")
 		data.Set("audience", googleCloudSDKAudience)
 
-		req, err := http.NewRequestWithContext(ctx, "POST", googleTokenInfoURL, strings.NewReader(data.Encode()))
+		req, err := http.NewRequestWithContext(ctx, "POST", googleTokenURL, strings.NewReader(data.Encode()))
 		if err != nil {
 			return "", fmt.Errorf("failed to create token request: %w", err)
 		}
@@ -264,7 +442,7 @@ func generateIdentityToken(ctx context.Context) (string, error) {
 		}
 
 		if resp.StatusCode != http.StatusOK {
-			return "", fmt.Errorf("token request failed with status %d: %s", resp.StatusCode, body)
+			return "", fmt.Errorf("token request failed with %w", &retry.StatusError{StatusCode: resp.StatusCode, Body: string(body)})
 		}
 
 		var tokenResp struct {
@@ -293,5 +471,10 @@ func generateIdentityToken(ctx context.Context) (string, error) {
 		return token.AccessToken, nil
 	}
 
+	// Handle Workload Identity Federation credentials
+	if cf.Type == "external_account" {
+		return generateExternalAccountIdentityToken(ctx, creds.JSON, audience)
+	}
+
 	return "", fmt.Errorf("unsupported credential type: %s", cf.Type)
 }
@@ -0,0 +1,95 @@
+package gcp
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/compute/metadata"
+	"github.com/stretchr/testify/assert"
+
+	"janus/logger"
+	"janus/retry"
+	"janus/types"
+)
+
+// fakeMetadataServer makes detection of the metadata server succeed after the given number of
+// failed attempts, on a machine whose firmware reports productName
+func fakeMetadataServer(t *testing.T, productName string, failures int) *int {
+	path := filepath.Join(t.TempDir(), "product_name")
+	assert.NoError(t, os.WriteFile(path, []byte(productName+"\n"), 0o444))
+
+	file, detect := productNameFile, detectMetadataServer
+	t.Cleanup(func() {
+		productNameFile, detectMetadataServer = file, detect
+	})
+	productNameFile = path
+
+	attempts := 0
+	detectMetadataServer = func(context.Context, *metadata.Client) bool {
+		attempts++
+		return attempts > failures
+	}
+	return &attempts
+}
+
+func TestOnGCERetriesDetection(t *testing.T) {
+	attempts := fakeMetadataServer(t, "Google Compute Engine", 2)
+
+	client := NewMetadataClient(context.Background())
+	client.Retry = retry.Policy{Retries: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+
+	assert.True(t, client.OnGCE(context.Background()), "Metadata server starting late should be detected")
+	assert.Equal(t, 3, *attempts)
+
+	assert.True(t, client.OnGCE(context.Background()))
+	assert.Equal(t, 3, *attempts, "Detected metadata server should be remembered")
+}
+
+func TestOnGCEOutsideGoogle(t *testing.T) {
+	attempts := fakeMetadataServer(t, "Standard PC", 2)
+
+	client := NewMetadataClient(context.Background())
+	client.Retry = retry.Policy{Retries: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+
+	assert.False(t, client.OnGCE(context.Background()))
+	assert.Equal(t, 1, *attempts, "Detection should not be retried outside of Google machines")
+
+	assert.False(t, client.OnGCE(context.Background()))
+	assert.Equal(t, 2, *attempts, "Missing metadata server should not be remembered")
+}
+
+// TestUserCredentialsIgnoreAudience verifies that a configured audience which can't be applied to
+// tokens from user credentials is reported
+func TestUserCredentialsIgnoreAudience(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"id_token":"user-id-token"}`))
+	}))
+	defer server.Close()
+
+	defer func(endpoint string) { googleTokenURL = endpoint }(googleTokenURL)
+	googleTokenURL = server.URL
+
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"type":"authorized_user","client_id":"id","client_secret":"secret","refresh_token":"refresh"}`), 0o600))
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", path)
+
+	defer func(l *slog.Logger) { logger.Logger = l }(logger.Logger)
+	var logs bytes.Buffer
+	logger.Logger = slog.New(slog.NewJSONHandler(&logs, nil))
+
+	token, err := generateIdentityToken(context.Background(), types.Config{}, types.GCPTokenAudience)
+	assert.NoError(t, err)
+	assert.Equal(t, "user-id-token", token)
+	assert.Empty(t, logs.String())
+
+	_, err = generateIdentityToken(context.Background(), types.Config{}, "api://janus")
+	assert.NoError(t, err)
+	assert.Contains(t, logs.String(), "Ignoring the configured audience")
+}
@@ -0,0 +1,284 @@
+package gcp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"golang.org/x/oauth2/google"
+
+	"janus/kubernetes"
+	"janus/types"
+)
+
+const sessionNameHashLength = 8 // Hex characters of the hash rendered by {{.Hash}} and ending shortened session names
+
+// TemplateData holds the fields available to the -sourceidentity and -sessiontemplate templates.
+// Fields are looked up on first use, so rendering a template only queries the metadata it references.
+type TemplateData struct {
+	ctx    context.Context
+	config types.Config
+	client *MetadataClient
+	values map[string]string
+	// hash is returned by Hash, set while rendering a session name
+	hash string
+}
+
+// NewTemplateData returns template fields looked up from GCP metadata through client when running
+// on GCP, otherwise from local credentials and the OS
+func NewTemplateData(ctx context.Context, config types.Config, client *MetadataClient) *TemplateData {
+	return &TemplateData{ctx: ctx, config: config, client: client, values: make(map[string]string)}
+}
+
+// Email returns the email of the Google service account whose identity is used
+func (d *TemplateData) Email() (string, error) {
+	return d.lookup("Email", func() (string, error) {
+		return ServiceAccountEmail(d.ctx, d.config, d.client)
+	})
+}
+
+// Project returns the GCP project ID
+func (d *TemplateData) Project() (string, error) {
+	return d.lookup("Project", func() (string, error) {
+		if d.onGCE() {
+			return d.client.ProjectIDWithContext(d.ctx)
+		}
+		creds, err := google.FindDefaultCredentials(d.ctx)
+		if err != nil {
+			return "", fmt.Errorf("failed to get default credentials: %w", err)
+		}
+		if creds.ProjectID == "" {
+			return "", fmt.Errorf("default credentials name no project")
+		}
+		return creds.ProjectID, nil
+	})
+}
+
+// Hostname returns the hostname of the instance, or of the local machine outside of GCP
+func (d *TemplateData) Hostname() (string, error) {
+	return d.lookup("Hostname", func() (string, error) {
+		if d.onGCE() {
+			return d.client.HostnameWithContext(d.ctx)
+		}
+		return os.Hostname()
+	})
+}
+
+// Zone returns the GCE zone of the instance
+func (d *TemplateData) Zone() (string, error) {
+	return d.lookupMetadata("Zone", d.client.ZoneWithContext)
+}
+
+// Cluster returns the name of the GKE cluster the instance belongs to
+func (d *TemplateData) Cluster() (string, error) {
+	return d.lookupMetadata("Cluster", d.client.ClusterNameWithContext)
+}
+
+// Instance returns the name of the GCE instance
+func (d *TemplateData) Instance() (string, error) {
+	return d.lookupMetadata("Instance", d.client.InstanceNameWithContext)
+}
+
+// Pod returns the Kubernetes pod name
+func (d *TemplateData) Pod() (string, error) {
+	return d.lookup("Pod", kubernetes.PodName)
+}
+
+// Namespace returns the Kubernetes namespace of the pod
+func (d *TemplateData) Namespace() (string, error) {
+	return d.lookup("Namespace", kubernetes.Namespace)
+}
+
+// ServiceAccount returns the Kubernetes service account of the pod
+func (d *TemplateData) ServiceAccount() (string, error) {
+	return d.lookup("ServiceAccount", kubernetes.ServiceAccount)
+}
+
+// Node returns the Kubernetes node name exposed through the downward API as NODE_NAME
+func (d *TemplateData) Node() (string, error) {
+	return d.lookup("Node", func() (string, error) {
+		return downwardAPIValue(types.EnvNodeName)
+	})
+}
+
+// Hash returns a short stable hash of the session name rendered without it. It is empty
+// outside of session name templates.
+func (d *TemplateData) Hash() string {
+	return d.hash
+}
+
+// lookupMetadata returns the value of a field only available from the GCP metadata server
+func (d *TemplateData) lookupMetadata(field string, get func(context.Context) (string, error)) (string, error) {
+	return d.lookup(field, func() (string, error) {
+		if !d.onGCE() {
+			return "", fmt.Errorf("not running on GCP")
+		}
+		return get(d.ctx)
+	})
+}
+
+// downwardAPIValue returns the value of an environment variable set through the Kubernetes downward API
+func downwardAPIValue(name string) (string, error) {
+	if value := os.Getenv(name); value != "" {
+		return value, nil
+	}
+	return "", fmt.Errorf("%s is not set, expose it through the Kubernetes downward API", name)
+}
+
+// onGCE reports whether fields are looked up from GCP metadata
+func (d *TemplateData) onGCE() bool {
+	return d.client != nil && d.client.OnGCE(d.ctx)
+}
+
+// lookup returns the value of a field, calling get only the first time it is needed
+func (d *TemplateData) lookup(field string, get func() (string, error)) (string, error) {
+	if value, ok := d.values[field]; ok {
+		return value, nil
+	}
+	value, err := get()
+	if err != nil {
+		return "", fmt.Errorf("failed to look up %s: %w", field, err)
+	}
+	d.values[field] = value
+	return value, nil
+}
+
+// ParseTemplate parses a template over TemplateData fields, such as {{.Email}}
+func ParseTemplate(name, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s template: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+// RenderTemplate parses and executes a template with data
+func RenderTemplate(name, text string, data *TemplateData) (string, error) {
+	tmpl, err := ParseTemplate(name, text)
+	if err != nil {
+		return "", err
+	}
+	return executeTemplate(tmpl, data)
+}
+
+// RenderSessionName renders a session name template and turns the result into a valid role
+// session name. {{.Hash}} is rendered as a hash of the name rendered without it.
+func RenderSessionName(text string, data *TemplateData) (string, error) {
+	tmpl, err := ParseTemplate("session name", text)
+	if err != nil {
+		return "", err
+	}
+
+	data.hash = ""
+	unhashed, err := executeTemplate(tmpl, data)
+	if err != nil {
+		return "", err
+	}
+	data.hash = shortHash(unhashed)
+	name, err := executeTemplate(tmpl, data)
+	if err != nil {
+		return "", err
+	}
+
+	name = SanitizeSessionName(name)
+	if err := types.ValidateSessionName(name); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// executeTemplate executes a parsed template with data
+func executeTemplate(tmpl *template.Template, data *TemplateData) (string, error) {
+	var rendered strings.Builder
+	if err := tmpl.Execute(&rendered, data); err != nil {
+		return "", fmt.Errorf("failed to render %s template: %w", tmpl.Name(), err)
+	}
+	return rendered.String(), nil
+}
+
+// SanitizeSessionName replaces characters STS does not accept in role session names with
+// dashes. Names longer than STS allows are shortened, preferably at a separator, and end with
+// a hash of the full name so that shortened names stay distinct. Names shorter than STS allows,
+// such as a one letter hostname, are padded with the hash. Empty names stay empty.
+func SanitizeSessionName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_+=,.@-", r)) {
+			return r
+		}
+		return '-'
+	}, name)
+
+	if name != "" && len(name) < types.SessionNameMinLength {
+		return name + "-" + shortHash(name)
+	}
+	if len(name) <= types.SessionNameMaxLength {
+		return name
+	}
+
+	const separators = "-_.@"
+	hash := shortHash(name)
+	cut := types.SessionNameMaxLength - len(hash) - 1
+	prefix := name[:cut]
+	if !strings.ContainsRune(separators, rune(name[cut])) {
+		if i := strings.LastIndexAny(prefix, separators); i >= len(prefix)/2 {
+			prefix = prefix[:i]
+		}
+	}
+	// Avoid a doubled separator before the hash
+	prefix = strings.TrimRight(prefix, separators)
+	if prefix == "" {
+		return hash
+	}
+	return prefix + "-" + hash
+}
+
+// shortHash returns the first characters of the hex encoded SHA-256 hash of s
+func shortHash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])[:sessionNameHashLength]
+}
+
+// ServiceAccountEmail returns the email of the Google service account whose identity tokens
+// are used: the impersonated service account, the service account of the instance from GCP
+// metadata when running on GCP with a client, or the service account of the default credentials
+func ServiceAccountEmail(ctx context.Context, config types.Config, client *MetadataClient) (string, error) {
+	if config.Impersonate != "" {
+		return config.Impersonate, nil
+	}
+
+	if client != nil && client.OnGCE(ctx) {
+		email, err := client.DefaultEmailWithContext(ctx)
+		if err == nil {
+			return email, nil
+		}
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		return "", fmt.Errorf("couldn't fetch service account email from GCP metadata server: %w", err)
+	}
+
+	creds, err := google.FindDefaultCredentials(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get default credentials: %w", err)
+	}
+
+	var cf credentialsFile
+	if err := json.Unmarshal(creds.JSON, &cf); err != nil {
+		return "", fmt.Errorf("failed to parse credentials: %w", err)
+	}
+
+	switch cf.Type {
+	case "service_account":
+		return cf.ClientEmail, nil
+	case "external_account":
+		serviceAccount, _, err := externalAccountServiceAccount(creds.JSON)
+		return serviceAccount, err
+	}
+	return "", fmt.Errorf("%s credentials have no service account email", cf.Type)
+}
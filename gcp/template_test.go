@@ -0,0 +1,142 @@
+package gcp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"janus/types"
+)
+
+// setupDefaultCredentials points application default credentials at a credentials file with the given content
+func setupDefaultCredentials(t *testing.T, content string) {
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", path)
+}
+
+func TestRenderTemplate(t *testing.T) {
+	setupDefaultCredentials(t, `{
+		"type": "service_account",
+		"project_id": "my-project",
+		"client_email": "aws-access@my-project.iam.gserviceaccount.com",
+		"private_key_id": "key-id",
+		"private_key": "not-a-key"
+	}`)
+	hostname, err := os.Hostname()
+	assert.NoError(t, err)
+
+	data := NewTemplateData(context.Background(), types.Config{}, nil)
+
+	rendered, err := RenderTemplate("test", "{{.Email}}", data)
+	assert.NoError(t, err)
+	assert.Equal(t, "aws-access@my-project.iam.gserviceaccount.com", rendered)
+
+	rendered, err = RenderTemplate("test", "{{.Project}}-{{.Hostname}}", data)
+	assert.NoError(t, err)
+	assert.Equal(t, "my-project-"+hostname, rendered)
+
+	rendered, err = RenderTemplate("test", "static", data)
+	assert.NoError(t, err)
+	assert.Equal(t, "static", rendered)
+
+	_, err = RenderTemplate("test", "{{.Unknown}}", data)
+	assert.Error(t, err, "unknown field")
+
+	_, err = RenderTemplate("test", "{{.Email", data)
+	assert.ErrorContains(t, err, "invalid test template")
+}
+
+func TestServiceAccountEmail(t *testing.T) {
+	ctx := context.Background()
+
+	email, err := ServiceAccountEmail(ctx, types.Config{Impersonate: "target@my-project.iam.gserviceaccount.com"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "target@my-project.iam.gserviceaccount.com", email, "impersonated service account takes precedence")
+
+	setupDefaultCredentials(t, `{
+		"type": "external_account",
+		"audience": "//iam.googleapis.com/projects/123/locations/global/workloadIdentityPools/pool/providers/provider",
+		"subject_token_type": "urn:ietf:params:oauth:token-type:jwt",
+		"token_url": "https://sts.googleapis.com/v1/token",
+		"credential_source": {"file": "/dev/null"},
+		"service_account_impersonation_url": "https://iamcredentials.googleapis.com/v1/projects/-/serviceAccounts/wif@my-project.iam.gserviceaccount.com:generateAccessToken"
+	}`)
+	email, err = ServiceAccountEmail(ctx, types.Config{}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "wif@my-project.iam.gserviceaccount.com", email)
+
+	setupDefaultCredentials(t, `{
+		"type": "authorized_user",
+		"client_id": "id",
+		"client_secret": "secret",
+		"refresh_token": "refresh"
+	}`)
+	_, err = ServiceAccountEmail(ctx, types.Config{}, nil)
+	assert.ErrorContains(t, err, "authorized_user credentials have no service account email")
+}
+
+func TestSanitizeSessionName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"my-project-host", "my-project-host"},
+		{"my project/host:1", "my-project-host-1"},
+		{"ops@example.com,team=a+b_c", "ops@example.com,team=a+b_c"},
+		{"pod-ñ", "pod--"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, SanitizeSessionName(tt.name), tt.name)
+	}
+
+	long := "my-project-" + strings.Repeat("gke-cluster-default-pool-", 4) + "abcdef"
+	shortened := SanitizeSessionName(long)
+	assert.LessOrEqual(t, len(shortened), types.SessionNameMaxLength)
+	assert.NoError(t, types.ValidateSessionName(shortened))
+	assert.Regexp(t, `^my-project-gke-cluster-default-pool-gke-cluster-default-[0-9a-f]{8}$`, shortened, "Cut at a separator and end with a hash")
+	assert.NotEqual(t, shortened, SanitizeSessionName(long+"x"), "Names differing after the cut stay distinct")
+	assert.Equal(t, shortened, SanitizeSessionName(long), "Shortening is stable")
+
+	midWord := SanitizeSessionName("my-project-" + strings.Repeat("a", 30) + "-" + strings.Repeat("b", 30))
+	assert.Regexp(t, `^my-project-a{30}-[0-9a-f]{8}$`, midWord, "Words are not cut in half")
+
+	separatorAtCut := SanitizeSessionName("my-project-" + strings.Repeat("a", 43) + "--" + strings.Repeat("b", 30))
+	assert.Regexp(t, `^my-project-a{43}-[0-9a-f]{8}$`, separatorAtCut, "Separators before the hash are not doubled")
+
+	assert.Regexp(t, `^[0-9a-f]{8}$`, SanitizeSessionName(strings.Repeat("/", 70)), "Names of separators only become the hash")
+
+	short := SanitizeSessionName("a")
+	assert.Equal(t, "a-"+shortHash("a"), short, "One letter names are padded with the hash")
+	assert.NoError(t, types.ValidateSessionName(short))
+	assert.Equal(t, "", SanitizeSessionName(""), "Empty names stay empty")
+}
+
+func TestRenderSessionName(t *testing.T) {
+	t.Setenv(types.EnvPodName, "checkout-7d9f8b6c5d-x2x4z")
+	t.Setenv(types.EnvPodNamespace, "payments")
+	t.Setenv(types.EnvNodeName, "")
+
+	data := NewTemplateData(context.Background(), types.Config{}, nil)
+
+	name, err := RenderSessionName("{{.Namespace}}/{{.Pod}}", data)
+	assert.NoError(t, err)
+	assert.Equal(t, "payments-checkout-7d9f8b6c5d-x2x4z", name)
+
+	name, err = RenderSessionName("{{.Pod}}-{{.Hash}}", data)
+	assert.NoError(t, err)
+	assert.Equal(t, "checkout-7d9f8b6c5d-x2x4z-"+shortHash("checkout-7d9f8b6c5d-x2x4z-"), name)
+
+	_, err = RenderSessionName("{{.Node}}", data)
+	assert.ErrorContains(t, err, types.EnvNodeName)
+
+	_, err = RenderSessionName("{{.Zone}}", data)
+	assert.ErrorContains(t, err, "not running on GCP")
+
+	_, err = RenderSessionName(`{{if false}}x{{end}}`, data)
+	assert.Error(t, err, "Empty session names are rejected")
+}
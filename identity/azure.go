@@ -0,0 +1,86 @@
+package identity
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"golang.org/x/oauth2"
+
+	"janus/logger"
+	"janus/types"
+)
+
+// azureIMDSEndpoint is the managed identity token endpoint of the Azure instance metadata service
+var azureIMDSEndpoint = "http://169.254.169.254/metadata/identity/oauth2/token"
+
+const (
+	azureIMDSAPIVersion     = "2018-02-01" // Managed identity API version of the instance metadata service
+	azureIdentityAPIVersion = "2019-08-01" // Managed identity API version of App Service and Container Apps
+)
+
+// azureProvider requests tokens for an Azure managed identity, using the IDENTITY_ENDPOINT of
+// App Service and Container Apps when set and the instance metadata service otherwise.
+// The audience is requested as the token resource, typically an application ID URI, and must
+// be configured as Azure has no resource matching the Google default.
+type azureProvider struct{}
+
+// Name returns the provider name
+func (azureProvider) Name() string {
+	return types.ProviderAzure
+}
+
+// TokenSource returns a token source requesting a managed identity token on every call
+func (azureProvider) TokenSource(ctx context.Context, config types.Config) (oauth2.TokenSource, error) {
+	audience := Audience(config)
+	if audience == "" {
+		return nil, fmt.Errorf("the %s identity provider requires -audience or %s, typically the application ID URI", types.ProviderAzure, types.EnvTokenAudience)
+	}
+	return retryTokenSource(ctx, config, func() (string, error) {
+		return azureIdentityToken(ctx, audience)
+	}), nil
+}
+
+// azureIdentityToken requests a managed identity token for the audience
+func azureIdentityToken(ctx context.Context, audience string) (string, error) {
+	query := url.Values{}
+	query.Set("resource", audience)
+	if clientID := os.Getenv(types.EnvAzureClientID); clientID != "" {
+		query.Set("client_id", clientID)
+	}
+
+	endpoint := os.Getenv(types.EnvAzureIdentityEndpoint)
+	identityHeader := os.Getenv(types.EnvAzureIdentityHeader)
+	appService := endpoint != "" && identityHeader != ""
+	if appService {
+		query.Set("api-version", azureIdentityAPIVersion)
+	} else {
+		endpoint = azureIMDSEndpoint
+		query.Set("api-version", azureIMDSAPIVersion)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create Azure token request: %w", err)
+	}
+	if appService {
+		req.Header.Set("X-IDENTITY-HEADER", identityHeader)
+	} else {
+		req.Header.Set("Metadata", "true")
+	}
+
+	logger.Logger.Debug("Requesting Azure managed identity token", "endpoint", endpoint, "resource", audience)
+	var tokenResp struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := doJSON(req, &tokenResp); err != nil {
+		return "", fmt.Errorf("failed to get Azure managed identity token: %w", err)
+	}
+	if tokenResp.AccessToken == "" {
+		return "", fmt.Errorf("Azure managed identity token response contained no token")
+	}
+
+	return tokenResp.AccessToken, nil
+}
@@ -0,0 +1,84 @@
+package identity
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"janus/types"
+)
+
+func TestAzureProviderInstanceMetadata(t *testing.T) {
+	var gotRequest *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotRequest = r
+		_, _ = w.Write([]byte(`{"access_token":"azure-vm-token","token_type":"Bearer"}`))
+	}))
+	defer server.Close()
+
+	defer func(endpoint string) { azureIMDSEndpoint = endpoint }(azureIMDSEndpoint)
+	azureIMDSEndpoint = server.URL + "/metadata/identity/oauth2/token"
+
+	clearProviderEnvironment(t)
+	t.Setenv(types.EnvAzureClientID, "client-id")
+
+	tokenSource, err := azureProvider{}.TokenSource(context.Background(), types.Config{Audience: "api://janus"})
+	assert.NoError(t, err)
+
+	token, err := tokenSource.Token()
+	assert.NoError(t, err)
+	assert.Equal(t, "azure-vm-token", token.AccessToken)
+	assert.Equal(t, "/metadata/identity/oauth2/token", gotRequest.URL.Path)
+	assert.Equal(t, "api://janus", gotRequest.URL.Query().Get("resource"))
+	assert.Equal(t, azureIMDSAPIVersion, gotRequest.URL.Query().Get("api-version"))
+	assert.Equal(t, "client-id", gotRequest.URL.Query().Get("client_id"))
+	assert.Equal(t, "true", gotRequest.Header.Get("Metadata"))
+}
+
+func TestAzureProviderAppService(t *testing.T) {
+	var gotRequest *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotRequest = r
+		_, _ = w.Write([]byte(`{"access_token":"azure-app-token"}`))
+	}))
+	defer server.Close()
+
+	clearProviderEnvironment(t)
+	t.Setenv(types.EnvAzureIdentityEndpoint, server.URL+"/msi/token")
+	t.Setenv(types.EnvAzureIdentityHeader, "identity-secret")
+
+	tokenSource, err := azureProvider{}.TokenSource(context.Background(), types.Config{Audience: "api://janus"})
+	assert.NoError(t, err)
+
+	token, err := tokenSource.Token()
+	assert.NoError(t, err)
+	assert.Equal(t, "azure-app-token", token.AccessToken)
+	assert.Equal(t, "/msi/token", gotRequest.URL.Path)
+	assert.Equal(t, azureIdentityAPIVersion, gotRequest.URL.Query().Get("api-version"))
+	assert.Equal(t, "identity-secret", gotRequest.Header.Get("X-IDENTITY-HEADER"))
+}
+
+func TestAzureProviderError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":"invalid_resource"}`, http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	defer func(endpoint string) { azureIMDSEndpoint = endpoint }(azureIMDSEndpoint)
+	azureIMDSEndpoint = server.URL
+
+	clearProviderEnvironment(t)
+	_, err := azureIdentityToken(context.Background(), "api://janus")
+	assert.ErrorContains(t, err, "status 400")
+	assert.ErrorContains(t, err, "invalid_resource")
+}
+
+func TestAzureProviderWithoutAudience(t *testing.T) {
+	clearProviderEnvironment(t)
+
+	_, err := azureProvider{}.TokenSource(context.Background(), types.Config{Provider: types.ProviderAzure})
+	assert.ErrorContains(t, err, "requires -audience")
+}
@@ -0,0 +1,61 @@
+// Package identity provides sources of OIDC identity tokens exchanged for AWS credentials
+package identity
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/oauth2"
+
+	"janus/types"
+)
+
+// fileProvider reads identity tokens from a file
+type fileProvider struct {
+	path string
+}
+
+// Name returns the provider name
+func (fileProvider) Name() string {
+	return types.ProviderFile
+}
+
+// TokenSource returns a token source reading the token file on every call
+func (p fileProvider) TokenSource(_ context.Context, _ types.Config) (oauth2.TokenSource, error) {
+	retriever := FileTokenRetriever{Path: p.path}
+	return tokenSourceFunc(func() (string, error) {
+		token, err := retriever.GetIdentityToken()
+		return string(token), err
+	}), nil
+}
+
+// FileTokenRetriever reads an identity token from a file, such as a projected Kubernetes
+// service account token. The file is read on every call so rotated tokens are picked up.
+type FileTokenRetriever struct {
+	Path string
+}
+
+// GetIdentityToken reads the identity token from the file
+func (r FileTokenRetriever) GetIdentityToken() ([]byte, error) {
+	data, err := os.ReadFile(r.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read identity token file: %w", err)
+	}
+
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return nil, fmt.Errorf("identity token file %s is empty", r.Path)
+	}
+	return []byte(token), nil
+}
+
+// TokenFile returns the identity token file path taken from the configuration or the
+// JANUS_TOKEN_FILE environment variable, or an empty string when no token file is used
+func TokenFile(config types.Config) string {
+	if config.TokenFile != "" {
+		return config.TokenFile
+	}
+	return os.Getenv(types.EnvTokenFile)
+}
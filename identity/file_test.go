@@ -0,0 +1,43 @@
+package identity
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"janus/types"
+)
+
+func TestFileTokenRetriever(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	retriever := FileTokenRetriever{Path: path}
+
+	_, err := retriever.GetIdentityToken()
+	assert.Error(t, err, "missing file")
+
+	assert.NoError(t, os.WriteFile(path, []byte("first-token\n"), 0600))
+	token, err := retriever.GetIdentityToken()
+	assert.NoError(t, err)
+	assert.Equal(t, "first-token", string(token))
+
+	// Rotated tokens are picked up on the next call
+	assert.NoError(t, os.WriteFile(path, []byte("second-token"), 0600))
+	token, err = retriever.GetIdentityToken()
+	assert.NoError(t, err)
+	assert.Equal(t, "second-token", string(token))
+
+	assert.NoError(t, os.WriteFile(path, []byte(" \n"), 0600))
+	_, err = retriever.GetIdentityToken()
+	assert.ErrorContains(t, err, "empty")
+}
+
+func TestTokenFile(t *testing.T) {
+	t.Setenv(types.EnvTokenFile, "")
+	assert.Equal(t, "", TokenFile(types.Config{}))
+
+	t.Setenv(types.EnvTokenFile, "/var/run/secrets/env-token")
+	assert.Equal(t, "/var/run/secrets/env-token", TokenFile(types.Config{}))
+	assert.Equal(t, "/var/run/secrets/flag-token", TokenFile(types.Config{TokenFile: "/var/run/secrets/flag-token"}))
+}
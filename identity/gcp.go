@@ -0,0 +1,23 @@
+package identity
+
+import (
+	"context"
+
+	"golang.org/x/oauth2"
+
+	"janus/gcp"
+	"janus/types"
+)
+
+// gcpProvider mints Google identity tokens from the metadata server or application default credentials
+type gcpProvider struct{}
+
+// Name returns the provider name
+func (gcpProvider) Name() string {
+	return types.ProviderGCP
+}
+
+// TokenSource returns a token source of Google identity tokens
+func (gcpProvider) TokenSource(ctx context.Context, config types.Config) (oauth2.TokenSource, error) {
+	return gcp.TokenSource(ctx, config, Audience(config))
+}
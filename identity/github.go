@@ -0,0 +1,68 @@
+package identity
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"golang.org/x/oauth2"
+
+	"janus/logger"
+	"janus/types"
+)
+
+// githubProvider requests OIDC tokens from GitHub Actions. The workflow needs the
+// id-token: write permission for the request variables to be set.
+type githubProvider struct{}
+
+// Name returns the provider name
+func (githubProvider) Name() string {
+	return types.ProviderGitHub
+}
+
+// TokenSource returns a token source requesting a new GitHub Actions OIDC token on every call
+func (githubProvider) TokenSource(ctx context.Context, config types.Config) (oauth2.TokenSource, error) {
+	audience := Audience(config)
+	return retryTokenSource(ctx, config, func() (string, error) {
+		return gitHubIdentityToken(ctx, audience)
+	}), nil
+}
+
+// gitHubIdentityToken requests an OIDC token for the audience from the GitHub Actions token endpoint
+func gitHubIdentityToken(ctx context.Context, audience string) (string, error) {
+	requestURL := os.Getenv(types.EnvGitHubTokenRequestURL)
+	requestToken := os.Getenv(types.EnvGitHubTokenRequestToken)
+	if requestURL == "" || requestToken == "" {
+		return "", fmt.Errorf("%s and %s are not set, grant the workflow the id-token: write permission", types.EnvGitHubTokenRequestURL, types.EnvGitHubTokenRequestToken)
+	}
+
+	u, err := url.Parse(requestURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse %s: %w", types.EnvGitHubTokenRequestURL, err)
+	}
+	query := u.Query()
+	query.Set("audience", audience)
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create GitHub token request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+requestToken)
+	req.Header.Set("Accept", "application/json")
+
+	logger.Logger.Debug("Requesting GitHub Actions identity token", "audience", audience)
+	var tokenResp struct {
+		Value string `json:"value"`
+	}
+	if err := doJSON(req, &tokenResp); err != nil {
+		return "", fmt.Errorf("failed to get GitHub Actions identity token: %w", err)
+	}
+	if tokenResp.Value == "" {
+		return "", fmt.Errorf("GitHub Actions token response contained no token")
+	}
+
+	return tokenResp.Value, nil
+}
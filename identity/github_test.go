@@ -0,0 +1,73 @@
+package identity
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"janus/types"
+)
+
+func TestGitHubProvider(t *testing.T) {
+	var gotAudience, gotAPIVersion, gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAudience = r.URL.Query().Get("audience")
+		gotAPIVersion = r.URL.Query().Get("api-version")
+		gotAuth = r.Header.Get("Authorization")
+		_, _ = w.Write([]byte(`{"count":1,"value":"github-id-token"}`))
+	}))
+	defer server.Close()
+
+	clearProviderEnvironment(t)
+	t.Setenv(types.EnvGitHubTokenRequestURL, server.URL+"/token?api-version=2.0")
+	t.Setenv(types.EnvGitHubTokenRequestToken, "request-token")
+
+	tokenSource, err := githubProvider{}.TokenSource(context.Background(), types.Config{Audience: "sts.amazonaws.com"})
+	assert.NoError(t, err)
+
+	token, err := tokenSource.Token()
+	assert.NoError(t, err)
+	assert.Equal(t, "github-id-token", token.AccessToken)
+	assert.Equal(t, "sts.amazonaws.com", gotAudience)
+	assert.Equal(t, "2.0", gotAPIVersion)
+	assert.Equal(t, "Bearer request-token", gotAuth)
+}
+
+func TestGitHubProviderWithoutPermission(t *testing.T) {
+	clearProviderEnvironment(t)
+
+	tokenSource, err := githubProvider{}.TokenSource(context.Background(), types.Config{})
+	assert.NoError(t, err)
+
+	_, err = tokenSource.Token()
+	assert.ErrorContains(t, err, "id-token: write")
+}
+
+func TestGitHubProviderRetriesTransientErrors(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"value":"github-id-token"}`))
+	}))
+	defer server.Close()
+
+	clearProviderEnvironment(t)
+	t.Setenv(types.EnvGitHubTokenRequestURL, server.URL)
+	t.Setenv(types.EnvGitHubTokenRequestToken, "request-token")
+
+	tokenSource, err := githubProvider{}.TokenSource(context.Background(), types.Config{Retries: 3, RetryDelay: time.Millisecond})
+	assert.NoError(t, err)
+
+	token, err := tokenSource.Token()
+	assert.NoError(t, err)
+	assert.Equal(t, "github-id-token", token.AccessToken)
+	assert.Equal(t, 3, calls)
+}
@@ -0,0 +1,53 @@
+package identity
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"golang.org/x/oauth2"
+
+	"janus/logger"
+	"janus/types"
+)
+
+// gitLabProvider reads OIDC tokens which GitLab CI injects into job variables. The token
+// audience is set by the id_tokens keyword of the pipeline, not by janus-go.
+type gitLabProvider struct{}
+
+// Name returns the provider name
+func (gitLabProvider) Name() string {
+	return types.ProviderGitLab
+}
+
+// TokenSource returns a token source reading the GitLab job identity token
+func (gitLabProvider) TokenSource(_ context.Context, _ types.Config) (oauth2.TokenSource, error) {
+	return tokenSourceFunc(gitLabIdentityToken), nil
+}
+
+// gitLabIdentityToken returns the identity token from the GitLab job variable
+func gitLabIdentityToken() (string, error) {
+	name := gitLabTokenVariable()
+	if name == "" {
+		return "", fmt.Errorf("no GitLab identity token found, define id_tokens %s in the job or name the variable with %s", types.EnvGitLabIDToken, types.EnvGitLabTokenVariable)
+	}
+
+	logger.Logger.Debug("Reading GitLab CI identity token", "variable", name)
+	return os.Getenv(name), nil
+}
+
+// gitLabTokenVariable returns the name of the first set job variable holding an identity token:
+// the one named by JANUS_GITLAB_TOKEN_VARIABLE, GITLAB_OIDC_TOKEN or the deprecated CI_JOB_JWT_V2
+func gitLabTokenVariable() string {
+	names := []string{types.EnvGitLabIDToken, types.EnvGitLabJobJWT}
+	if name := os.Getenv(types.EnvGitLabTokenVariable); name != "" {
+		names = []string{name}
+	}
+
+	for _, name := range names {
+		if os.Getenv(name) != "" {
+			return name
+		}
+	}
+	return ""
+}
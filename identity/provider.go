@@ -0,0 +1,149 @@
+package identity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"golang.org/x/oauth2"
+
+	"janus/exitcode"
+	"janus/retry"
+	"janus/types"
+)
+
+// Provider is a source of OIDC identity tokens exchanged for AWS credentials
+type Provider interface {
+	// Name returns the provider name accepted by the -provider flag
+	Name() string
+	// TokenSource returns a token source whose access tokens are identity tokens
+	TokenSource(ctx context.Context, config types.Config) (oauth2.TokenSource, error)
+}
+
+// ProviderName returns the configured identity provider. When none or "auto" is configured
+// the provider is detected from the environment, falling back to Google credentials.
+func ProviderName(config types.Config) string {
+	if config.Provider != "" && config.Provider != types.ProviderAuto {
+		return config.Provider
+	}
+
+	switch {
+	case TokenFile(config) != "":
+		return types.ProviderFile
+	case os.Getenv(types.EnvGitHubTokenRequestURL) != "" && os.Getenv(types.EnvGitHubTokenRequestToken) != "":
+		return types.ProviderGitHub
+	case os.Getenv(types.EnvGitLabCI) == "true" && gitLabTokenVariable() != "":
+		return types.ProviderGitLab
+	case os.Getenv(types.EnvAzureIdentityEndpoint) != "" && os.Getenv(types.EnvAzureIdentityHeader) != "":
+		return types.ProviderAzure
+	}
+	return types.ProviderGCP
+}
+
+// NewProvider returns the identity provider selected by the configuration or detected from
+// the environment
+func NewProvider(config types.Config) (Provider, error) {
+	switch name := ProviderName(config); name {
+	case types.ProviderGCP:
+		return gcpProvider{}, nil
+	case types.ProviderFile:
+		path := TokenFile(config)
+		if path == "" {
+			return nil, fmt.Errorf("the file identity provider requires -tokenfile or %s", types.EnvTokenFile)
+		}
+		return fileProvider{path: path}, nil
+	case types.ProviderGitHub:
+		return githubProvider{}, nil
+	case types.ProviderGitLab:
+		return gitLabProvider{}, nil
+	case types.ProviderAzure:
+		return azureProvider{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported identity provider: %s", name)
+	}
+}
+
+// Audience returns the audience requested from the identity provider, taken from the
+// configuration or IDENTITY_TOKEN_AUDIENCE environment variable. Google and GitHub tokens
+// default to the audience expected by their AWS trust policies, other providers have no default.
+func Audience(config types.Config) string {
+	if config.Audience != "" {
+		return config.Audience
+	}
+	if audience := os.Getenv(types.EnvTokenAudience); audience != "" {
+		return audience
+	}
+
+	switch ProviderName(config) {
+	case types.ProviderGCP:
+		return types.GCPTokenAudience
+	case types.ProviderGitHub:
+		return types.GitHubTokenAudience
+	}
+	return ""
+}
+
+// TokenRetriever implements the identity token retrieval used by the AWS web identity provider
+type TokenRetriever struct {
+	TokenSource oauth2.TokenSource
+}
+
+// GetIdentityToken retrieves the identity token from the token source
+func (r TokenRetriever) GetIdentityToken() ([]byte, error) {
+	token, err := r.TokenSource.Token()
+	if err != nil {
+		return nil, exitcode.Wrap(exitcode.ClassIdentity, fmt.Errorf("couldn't retrieve identity token: %w", err))
+	}
+	return []byte(token.AccessToken), nil
+}
+
+// retryTokenSource returns a token source calling fetch with the retry policy of the configuration
+func retryTokenSource(ctx context.Context, config types.Config, fetch func() (string, error)) oauth2.TokenSource {
+	policy := retry.NewPolicy(config.Retries, config.RetryDelay)
+	return tokenSourceFunc(func() (string, error) {
+		var token string
+		err := retry.Do(ctx, policy, retry.IsTransient, func() (err error) {
+			token, err = fetch()
+			return err
+		})
+		return token, err
+	})
+}
+
+// tokenSourceFunc adapts a function returning identity tokens to oauth2.TokenSource
+type tokenSourceFunc func() (string, error)
+
+// Token returns the identity token as the access token
+func (f tokenSourceFunc) Token() (*oauth2.Token, error) {
+	token, err := f()
+	if err != nil {
+		return nil, err
+	}
+	return &oauth2.Token{AccessToken: token}, nil
+}
+
+// doJSON executes an HTTP request and decodes a successful JSON response into target
+func doJSON(req *http.Request, target any) error {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to execute token request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read token response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("token request failed with %w", &retry.StatusError{StatusCode: resp.StatusCode, Body: string(body)})
+	}
+
+	if err := json.Unmarshal(body, target); err != nil {
+		return fmt.Errorf("failed to parse token response: %w", err)
+	}
+	return nil
+}
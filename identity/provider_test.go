@@ -0,0 +1,191 @@
+package identity
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"janus/logger"
+	"janus/types"
+)
+
+func init() {
+	// Initialize logger for tests
+	logger.InitLogger("ERROR")
+}
+
+// clearProviderEnvironment unsets every variable used for identity provider detection
+func clearProviderEnvironment(t *testing.T) {
+	for _, name := range []string{
+		types.EnvTokenFile,
+		types.EnvGitHubTokenRequestURL,
+		types.EnvGitHubTokenRequestToken,
+		types.EnvGitLabCI,
+		types.EnvGitLabTokenVariable,
+		types.EnvGitLabIDToken,
+		types.EnvGitLabJobJWT,
+		types.EnvAzureIdentityEndpoint,
+		types.EnvAzureIdentityHeader,
+		types.EnvAzureClientID,
+		types.EnvTokenAudience,
+	} {
+		t.Setenv(name, "")
+	}
+}
+
+func TestProviderName(t *testing.T) {
+	tests := []struct {
+		name   string
+		config types.Config
+		env    map[string]string
+		want   string
+	}{
+		{
+			name: "defaults to gcp",
+			want: types.ProviderGCP,
+		},
+		{
+			name:   "explicit provider",
+			config: types.Config{Provider: types.ProviderAzure},
+			env:    map[string]string{types.EnvGitHubTokenRequestURL: "https://token", types.EnvGitHubTokenRequestToken: "secret"},
+			want:   types.ProviderAzure,
+		},
+		{
+			name:   "token file",
+			config: types.Config{Provider: types.ProviderAuto, TokenFile: "/var/run/token"},
+			want:   types.ProviderFile,
+		},
+		{
+			name: "github actions",
+			env:  map[string]string{types.EnvGitHubTokenRequestURL: "https://token", types.EnvGitHubTokenRequestToken: "secret"},
+			want: types.ProviderGitHub,
+		},
+		{
+			name: "github actions without id-token permission",
+			env:  map[string]string{types.EnvGitHubTokenRequestURL: "https://token"},
+			want: types.ProviderGCP,
+		},
+		{
+			name: "gitlab id_tokens",
+			env:  map[string]string{types.EnvGitLabCI: "true", types.EnvGitLabIDToken: "jwt"},
+			want: types.ProviderGitLab,
+		},
+		{
+			name: "gitlab without token",
+			env:  map[string]string{types.EnvGitLabCI: "true"},
+			want: types.ProviderGCP,
+		},
+		{
+			name: "azure app service",
+			env:  map[string]string{types.EnvAzureIdentityEndpoint: "http://localhost:8081/msi/token", types.EnvAzureIdentityHeader: "secret"},
+			want: types.ProviderAzure,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearProviderEnvironment(t)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			assert.Equal(t, tt.want, ProviderName(tt.config))
+		})
+	}
+}
+
+func TestAudience(t *testing.T) {
+	tests := []struct {
+		name   string
+		config types.Config
+		env    string
+		want   string
+	}{
+		{
+			name:   "gcp default",
+			config: types.Config{Provider: types.ProviderGCP},
+			want:   types.GCPTokenAudience,
+		},
+		{
+			name:   "github default",
+			config: types.Config{Provider: types.ProviderGitHub},
+			want:   types.GitHubTokenAudience,
+		},
+		{
+			name:   "azure has no default",
+			config: types.Config{Provider: types.ProviderAzure},
+			want:   "",
+		},
+		{
+			name:   "environment overrides default",
+			config: types.Config{Provider: types.ProviderGitHub},
+			env:    "env-audience",
+			want:   "env-audience",
+		},
+		{
+			name:   "configuration overrides environment",
+			config: types.Config{Provider: types.ProviderAzure, Audience: "api://janus"},
+			env:    "env-audience",
+			want:   "api://janus",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearProviderEnvironment(t)
+			t.Setenv(types.EnvTokenAudience, tt.env)
+			assert.Equal(t, tt.want, Audience(tt.config))
+		})
+	}
+}
+
+func TestNewProvider(t *testing.T) {
+	clearProviderEnvironment(t)
+
+	for _, name := range []string{types.ProviderGCP, types.ProviderGitHub, types.ProviderGitLab, types.ProviderAzure} {
+		provider, err := NewProvider(types.Config{Provider: name})
+		assert.NoError(t, err)
+		assert.Equal(t, name, provider.Name())
+	}
+
+	_, err := NewProvider(types.Config{Provider: types.ProviderFile})
+	assert.ErrorContains(t, err, "-tokenfile")
+
+	_, err = NewProvider(types.Config{Provider: "aws"})
+	assert.ErrorContains(t, err, "unsupported identity provider")
+}
+
+func TestGitLabProvider(t *testing.T) {
+	clearProviderEnvironment(t)
+	tokenSource, err := gitLabProvider{}.TokenSource(context.Background(), types.Config{})
+	assert.NoError(t, err)
+
+	_, err = tokenSource.Token()
+	assert.ErrorContains(t, err, "no GitLab identity token")
+
+	t.Setenv(types.EnvGitLabJobJWT, "legacy-jwt")
+	token, err := tokenSource.Token()
+	assert.NoError(t, err)
+	assert.Equal(t, "legacy-jwt", token.AccessToken)
+
+	t.Setenv(types.EnvGitLabIDToken, "id-token")
+	token, err = tokenSource.Token()
+	assert.NoError(t, err)
+	assert.Equal(t, "id-token", token.AccessToken)
+
+	t.Setenv(types.EnvGitLabTokenVariable, "AWS_ID_TOKEN")
+	t.Setenv("AWS_ID_TOKEN", "custom-id-token")
+	token, err = tokenSource.Token()
+	assert.NoError(t, err)
+	assert.Equal(t, "custom-id-token", token.AccessToken)
+}
+
+func TestTokenRetriever(t *testing.T) {
+	retriever := TokenRetriever{TokenSource: tokenSourceFunc(func() (string, error) {
+		return "identity-token", nil
+	})}
+
+	token, err := retriever.GetIdentityToken()
+	assert.NoError(t, err)
+	assert.Equal(t, "identity-token", string(token))
+}
@@ -0,0 +1,84 @@
+// Package kubernetes reads the identity of the pod janus-go runs in from downward API environment
+// variables and its service account mount
+package kubernetes
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"janus/types"
+)
+
+// serviceAccountSubjectPrefix prefixes the subject of service account tokens, which is
+// system:serviceaccount:NAMESPACE:NAME
+const serviceAccountSubjectPrefix = "system:serviceaccount:"
+
+// serviceAccountDir is where the kubelet mounts the service account token and namespace of a pod
+var serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// Detected reports whether janus-go runs in a Kubernetes pod, which has the API server address
+// in its environment or a service account token mounted
+func Detected() bool {
+	if os.Getenv(types.EnvKubernetesServiceHost) != "" {
+		return true
+	}
+	_, err := os.Stat(filepath.Join(serviceAccountDir, "token"))
+	return err == nil
+}
+
+// PodName returns the name of the pod from POD_NAME, or the hostname which Kubernetes sets to
+// the pod name unless the pod uses the host network or a custom hostname
+func PodName() (string, error) {
+	if name := os.Getenv(types.EnvPodName); name != "" {
+		return name, nil
+	}
+	if !Detected() {
+		return "", fmt.Errorf("%s is not set and not running in Kubernetes", types.EnvPodName)
+	}
+	return os.Hostname()
+}
+
+// Namespace returns the namespace of the pod from POD_NAMESPACE or the service account mount
+func Namespace() (string, error) {
+	if namespace := os.Getenv(types.EnvPodNamespace); namespace != "" {
+		return namespace, nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(serviceAccountDir, "namespace"))
+	if err != nil {
+		return "", fmt.Errorf("failed to read Kubernetes namespace: %w", err)
+	}
+
+	namespace := strings.TrimSpace(string(data))
+	if namespace == "" {
+		return "", fmt.Errorf("kubernetes namespace file is empty")
+	}
+	return namespace, nil
+}
+
+// ServiceAccount returns the name of the Kubernetes service account of the pod from
+// POD_SERVICE_ACCOUNT, or the subject of its service account token
+func ServiceAccount() (string, error) {
+	if name := os.Getenv(types.EnvPodServiceAccount); name != "" {
+		return name, nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(serviceAccountDir, "token"))
+	if err != nil {
+		return "", fmt.Errorf("failed to read Kubernetes service account token: %w", err)
+	}
+
+	claims, err := types.ParseJWTClaims(strings.TrimSpace(string(data)))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse Kubernetes service account token: %w", err)
+	}
+
+	namespaceAndName, ok := strings.CutPrefix(claims.Subject, serviceAccountSubjectPrefix)
+	_, name, found := strings.Cut(namespaceAndName, ":")
+	if !ok || !found || name == "" {
+		return "", fmt.Errorf("unexpected Kubernetes service account token subject: %q", claims.Subject)
+	}
+	return name, nil
+}
@@ -0,0 +1,110 @@
+package kubernetes
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"janus/types"
+)
+
+// setupServiceAccountDir points the service account mount at a temporary directory holding
+// the given files
+func setupServiceAccountDir(t *testing.T, files map[string]string) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0600))
+	}
+
+	original := serviceAccountDir
+	serviceAccountDir = dir
+	t.Cleanup(func() { serviceAccountDir = original })
+}
+
+// serviceAccountToken returns an unsigned token with the given subject
+func serviceAccountToken(subject string) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256"}`))
+	claims := base64.RawURLEncoding.EncodeToString([]byte(`{"iss":"https://kubernetes.default.svc","sub":"` + subject + `"}`))
+	return header + "." + claims + ".signature"
+}
+
+func TestDetected(t *testing.T) {
+	t.Setenv(types.EnvKubernetesServiceHost, "")
+	setupServiceAccountDir(t, nil)
+	assert.False(t, Detected())
+
+	t.Setenv(types.EnvKubernetesServiceHost, "10.0.0.1")
+	assert.True(t, Detected(), "API server address in the environment")
+
+	t.Setenv(types.EnvKubernetesServiceHost, "")
+	setupServiceAccountDir(t, map[string]string{"token": serviceAccountToken("system:serviceaccount:payments:checkout")})
+	assert.True(t, Detected(), "service account token mounted")
+}
+
+func TestPodName(t *testing.T) {
+	t.Setenv(types.EnvKubernetesServiceHost, "")
+	t.Setenv(types.EnvPodName, "checkout-7d9f8b6c5d-x2x4z")
+	name, err := PodName()
+	assert.NoError(t, err)
+	assert.Equal(t, "checkout-7d9f8b6c5d-x2x4z", name)
+
+	t.Setenv(types.EnvPodName, "")
+	setupServiceAccountDir(t, nil)
+	_, err = PodName()
+	assert.Error(t, err, "not running in a pod")
+
+	t.Setenv(types.EnvKubernetesServiceHost, "10.0.0.1")
+	hostname, err := os.Hostname()
+	assert.NoError(t, err)
+	name, err = PodName()
+	assert.NoError(t, err)
+	assert.Equal(t, hostname, name, "pods are named after their hostname")
+}
+
+func TestNamespace(t *testing.T) {
+	t.Setenv(types.EnvPodNamespace, "")
+	setupServiceAccountDir(t, map[string]string{"namespace": "payments\n"})
+	namespace, err := Namespace()
+	assert.NoError(t, err)
+	assert.Equal(t, "payments", namespace)
+
+	setupServiceAccountDir(t, map[string]string{"namespace": ""})
+	_, err = Namespace()
+	assert.Error(t, err, "empty namespace file")
+
+	setupServiceAccountDir(t, nil)
+	_, err = Namespace()
+	assert.Error(t, err, "not running in a pod")
+
+	t.Setenv(types.EnvPodNamespace, "from-downward-api")
+	namespace, err = Namespace()
+	assert.NoError(t, err)
+	assert.Equal(t, "from-downward-api", namespace)
+}
+
+func TestServiceAccount(t *testing.T) {
+	t.Setenv(types.EnvPodServiceAccount, "")
+	setupServiceAccountDir(t, map[string]string{"token": serviceAccountToken("system:serviceaccount:payments:checkout") + "\n"})
+	name, err := ServiceAccount()
+	assert.NoError(t, err)
+	assert.Equal(t, "checkout", name)
+
+	for _, subject := range []string{"checkout", "system:serviceaccount:payments", "system:serviceaccount:payments:", "system:node:worker-1"} {
+		setupServiceAccountDir(t, map[string]string{"token": serviceAccountToken(subject)})
+		_, err := ServiceAccount()
+		assert.Error(t, err, subject)
+	}
+
+	setupServiceAccountDir(t, map[string]string{"token": "not-a-jwt"})
+	_, err = ServiceAccount()
+	assert.Error(t, err, "malformed token")
+
+	t.Setenv(types.EnvPodServiceAccount, "from-downward-api")
+	name, err = ServiceAccount()
+	assert.NoError(t, err)
+	assert.Equal(t, "from-downward-api", name)
+}
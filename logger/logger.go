@@ -1,20 +1,77 @@
 package logger
 
 import (
+	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"strings"
+
+	"janus/types"
 )
 
 // Logger is the global logger instance that can be used across packages
 var Logger *slog.Logger
 
-// InitLogger initializes the global logger with the specified level
+// Options configures the global logger
+type Options struct {
+	// Level is the minimum level logged (DEBUG, INFO, WARN, ERROR)
+	Level string
+	// Format is json or text, defaults to json
+	Format string
+	// Output is stderr, syslog or the path of a file logs are appended to, defaults to stderr
+	Output string
+}
+
+// InitLogger initializes the global logger with the specified level, writing JSON to stderr.
+// Logs never go to stdout, which carries credentials for credential_process.
 func InitLogger(level string) {
-	logLevel := parseLogLevel(level)
-	Logger = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
-		Level: logLevel,
-	}))
+	Logger = newLogger(os.Stderr, types.LogFormatJSON, level)
+}
+
+// Init initializes the global logger with the given options. When the log output cannot be
+// opened the logger writes to stderr and an error is returned.
+func Init(opts Options) error {
+	if opts.Format != "" && opts.Format != types.LogFormatJSON && opts.Format != types.LogFormatText {
+		InitLogger(opts.Level)
+		return fmt.Errorf("invalid log format: %s (expected one of %s, %s)", opts.Format, types.LogFormatJSON, types.LogFormatText)
+	}
+
+	w, err := openOutput(opts.Output)
+	if err != nil {
+		InitLogger(opts.Level)
+		return err
+	}
+
+	Logger = newLogger(w, opts.Format, opts.Level)
+	return nil
+}
+
+// openOutput returns the writer for a log output
+func openOutput(output string) (io.Writer, error) {
+	switch output {
+	case "", types.LogOutputStderr:
+		return os.Stderr, nil
+	case types.LogOutputSyslog:
+		return openSyslog()
+	default:
+		f, err := os.OpenFile(output, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open log file: %w", err)
+		}
+		return f, nil
+	}
+}
+
+// newLogger creates a logger writing records of the given format and level to w, with secrets redacted
+func newLogger(w io.Writer, format string, level string) *slog.Logger {
+	opts := &slog.HandlerOptions{
+		Level: parseLogLevel(level),
+	}
+	if format == types.LogFormatText {
+		return slog.New(NewRedactingHandler(slog.NewTextHandler(w, opts)))
+	}
+	return slog.New(NewRedactingHandler(slog.NewJSONHandler(w, opts)))
 }
 
 func parseLogLevel(level string) slog.Level {
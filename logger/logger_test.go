@@ -0,0 +1,44 @@
+package logger
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"janus/types"
+)
+
+func TestInitFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "janus.log")
+
+	assert.NoError(t, Init(Options{Level: "DEBUG", Output: path}))
+	Logger.Debug("json record", "key", "value")
+
+	assert.NoError(t, Init(Options{Level: "INFO", Format: types.LogFormatText, Output: path}))
+	Logger.Debug("filtered record")
+	Logger.Info("text record", "key", "value")
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	assert.Len(t, lines, 2)
+
+	var record map[string]any
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &record))
+	assert.Equal(t, "json record", record["msg"])
+	assert.Equal(t, "value", record["key"])
+
+	assert.Contains(t, lines[1], `msg="text record" key=value`)
+}
+
+func TestInitErrors(t *testing.T) {
+	assert.Error(t, Init(Options{Level: "INFO", Format: "xml"}))
+	assert.NotNil(t, Logger, "logger falls back to stderr")
+
+	assert.Error(t, Init(Options{Level: "INFO", Output: filepath.Join(t.TempDir(), "missing", "janus.log")}))
+	assert.NotNil(t, Logger, "logger falls back to stderr")
+}
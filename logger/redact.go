@@ -0,0 +1,159 @@
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"janus/types"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are attribute names whose values are always masked, compared in lower case
+// without separators
+var sensitiveKeys = map[string]bool{
+	"secretaccesskey": true,
+	"sessiontoken":    true,
+	"securitytoken":   true,
+	"token":           true,
+	"idtoken":         true,
+	"accesstoken":     true,
+	"refreshtoken":    true,
+	"identitytoken":   true,
+	"clientsecret":    true,
+	"privatekey":      true,
+	"password":        true,
+	"authorization":   true,
+}
+
+var (
+	// Three base64url segments with a JSON header, as in every JWT
+	jwtPattern = regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+	// STS session tokens start with a base64 encoded "\x21\n\torigin" or legacy "\x17\n\x06\x01"
+	sessionTokenPattern = regexp.MustCompile(`(IQoJb3JpZ2lu|FwoGZXIvYXdz)[A-Za-z0-9/+=]+`)
+	// Secrets following their name, as in JSON, ini files or environment variables
+	secretAssignmentPattern = regexp.MustCompile(`(?i)((?:aws_)?(?:secret_?access_?key|session_?token|security_?token)"?\s*[:=]\s*"?)[^"\s,}]+`)
+)
+
+// redactingHandler masks JWTs, AWS secret access keys and session tokens in log records
+// before passing them on
+type redactingHandler struct {
+	next slog.Handler
+}
+
+// NewRedactingHandler returns a handler masking secrets by attribute name and by pattern in
+// messages and attribute values before records are passed to next
+func NewRedactingHandler(next slog.Handler) slog.Handler {
+	return redactingHandler{next: next}
+}
+
+// Enabled reports whether the wrapped handler handles records of the level
+func (h redactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle redacts the record and passes it to the wrapped handler
+func (h redactingHandler) Handle(ctx context.Context, r slog.Record) error {
+	record := slog.NewRecord(r.Time, r.Level, Redact(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		record.AddAttrs(redactAttr(a))
+		return true
+	})
+	return h.next.Handle(ctx, record)
+}
+
+// WithAttrs returns a handler with the redacted attributes added
+func (h redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redactedAttrs := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redactedAttrs[i] = redactAttr(a)
+	}
+	return redactingHandler{next: h.next.WithAttrs(redactedAttrs)}
+}
+
+// WithGroup returns a handler starting the group
+func (h redactingHandler) WithGroup(name string) slog.Handler {
+	return redactingHandler{next: h.next.WithGroup(name)}
+}
+
+// redactAttr masks the attribute value when its name is sensitive and secrets within it otherwise
+func redactAttr(a slog.Attr) slog.Attr {
+	value := a.Value.Resolve()
+
+	if value.Kind() == slog.KindGroup {
+		group := value.Group()
+		redactedGroup := make([]slog.Attr, len(group))
+		for i, ga := range group {
+			redactedGroup[i] = redactAttr(ga)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(redactedGroup...)}
+	}
+
+	if sensitiveKeys[normalizeKey(a.Key)] {
+		return slog.String(a.Key, redacted)
+	}
+
+	switch value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, Redact(value.String()))
+	case slog.KindAny:
+		return slog.Attr{Key: a.Key, Value: redactAny(value)}
+	default:
+		return slog.Attr{Key: a.Key, Value: value}
+	}
+}
+
+// redactAny masks secrets in errors and in the JSON form of other values, such as credentials
+// structs. Values without secrets are kept as they are.
+func redactAny(value slog.Value) slog.Value {
+	if err, ok := value.Any().(error); ok {
+		return slog.StringValue(Redact(err.Error()))
+	}
+
+	data, err := json.Marshal(value.Any())
+	if err != nil {
+		return value
+	}
+	if text := string(data); Redact(text) != text {
+		return slog.StringValue(Redact(text))
+	}
+	return value
+}
+
+// Redact masks JWTs, session tokens and assigned secrets within s, for output written outside of the logger
+func Redact(s string) string {
+	s = jwtPattern.ReplaceAllString(s, redacted)
+	s = sessionTokenPattern.ReplaceAllString(s, redacted)
+	return secretAssignmentPattern.ReplaceAllString(s, "${1}"+redacted)
+}
+
+// normalizeKey lower cases an attribute name and removes separators, so that SessionToken,
+// session_token and session-token match the same sensitive key
+func normalizeKey(key string) string {
+	return strings.NewReplacer("_", "", "-", "", ".", "").Replace(strings.ToLower(key))
+}
+
+// JWTClaims returns the claims of a JWT relevant to AWS trust policies (iss, aud, sub, email
+// and exp) for logging in place of the token, which is never logged
+func JWTClaims(token string) slog.Value {
+	claims, err := types.ParseJWTClaims(token)
+	if err != nil {
+		return slog.StringValue(redacted)
+	}
+
+	attrs := []slog.Attr{
+		slog.String("iss", claims.Issuer),
+		slog.String("aud", strings.Join(claims.Audience, ",")),
+		slog.String("sub", claims.Subject),
+	}
+	if claims.Email != "" {
+		attrs = append(attrs, slog.String("email", claims.Email))
+	}
+	if exp := claims.ExpiresAt(); !exp.IsZero() {
+		attrs = append(attrs, slog.Time("exp", exp))
+	}
+	return slog.GroupValue(attrs...)
+}
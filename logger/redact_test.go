@@ -0,0 +1,101 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"janus/types"
+)
+
+const (
+	testSecretAccessKey = "wJalrXUtnFEMI/K7MDENG/bPxRfiCYEXAMPLEKEY"
+	testSessionToken    = "IQoJb3JpZ2luX2VjEJr//////////wEaCXVzLWVhc3QtMSJHMEUCIQDexample+token/value=="
+)
+
+// testJWT builds an unsigned JWT with the given claims payload
+func testJWT(payload string) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	return header + "." + base64.RawURLEncoding.EncodeToString([]byte(payload)) + ".c2lnbmF0dXJl"
+}
+
+// newTestLogger returns a redacting JSON logger writing to buf
+func newTestLogger(buf *bytes.Buffer) *slog.Logger {
+	return slog.New(NewRedactingHandler(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+}
+
+func TestRedactingHandler(t *testing.T) {
+	jwt := testJWT(`{"iss":"https://accounts.google.com","aud":"gcp","sub":"1234"}`)
+	credentials := &types.AWSTempCredentials{
+		Version:         1,
+		AccessKeyId:     "ASIAEXAMPLE",
+		SecretAccessKey: testSecretAccessKey,
+		SessionToken:    testSessionToken,
+	}
+
+	var buf bytes.Buffer
+	log := newTestLogger(&buf).With("session_token", testSessionToken)
+	log.Debug("exchanging token "+jwt,
+		"SecretAccessKey", testSecretAccessKey,
+		"id_token", jwt,
+		"header", "Bearer "+jwt,
+		"credentials", credentials,
+		"error", errors.New("request failed: aws_secret_access_key="+testSecretAccessKey),
+		slog.Group("response", "token", "opaque", "body", `{"SessionToken":"`+testSessionToken+`"}`),
+		"roleArn", "arn:aws:iam::123456789012:role/my-trusted-role",
+	)
+
+	output := buf.String()
+	for _, secret := range []string{jwt, testSecretAccessKey, testSessionToken, "opaque"} {
+		assert.NotContains(t, output, secret)
+	}
+	assert.Contains(t, output, "ASIAEXAMPLE", "access key IDs are not secret")
+	assert.Contains(t, output, "arn:aws:iam::123456789012:role/my-trusted-role")
+
+	var record map[string]any
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "exchanging token "+redacted, record["msg"])
+	assert.Equal(t, redacted, record["SecretAccessKey"])
+	assert.Equal(t, redacted, record["session_token"])
+	assert.Equal(t, "Bearer "+redacted, record["header"])
+}
+
+func TestRedactingHandlerKeepsOtherValues(t *testing.T) {
+	var buf bytes.Buffer
+	newTestLogger(&buf).Info("fetched", "attempt", 2, "delay", time.Second, "hop", types.RoleHop{RoleArn: "arn:aws:iam::123456789012:role/a"})
+
+	var record map[string]any
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, float64(2), record["attempt"])
+	assert.Equal(t, "arn:aws:iam::123456789012:role/a", record["hop"].(map[string]any)["RoleArn"])
+}
+
+func TestJWTClaims(t *testing.T) {
+	jwt := testJWT(`{"iss":"https://accounts.google.com","aud":"gcp","sub":"1234","email":"sa@my-project.iam.gserviceaccount.com","exp":1767225600,"azp":"5678"}`)
+
+	var buf bytes.Buffer
+	newTestLogger(&buf).Debug("Google identity token", "claims", JWTClaims(jwt))
+	assert.NotContains(t, buf.String(), jwt)
+	assert.NotContains(t, buf.String(), "c2lnbmF0dXJl", "signature is stripped")
+
+	var record struct {
+		Claims map[string]string `json:"claims"`
+	}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "https://accounts.google.com", record.Claims["iss"])
+	assert.Equal(t, "gcp", record.Claims["aud"])
+	assert.Equal(t, "1234", record.Claims["sub"])
+	assert.Equal(t, "sa@my-project.iam.gserviceaccount.com", record.Claims["email"])
+	exp, err := time.Parse(time.RFC3339, record.Claims["exp"])
+	assert.NoError(t, err)
+	assert.True(t, exp.Equal(time.Unix(1767225600, 0)))
+	assert.NotContains(t, record.Claims, "azp")
+
+	assert.Equal(t, redacted, JWTClaims("not-a-jwt").String())
+}
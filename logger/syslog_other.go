@@ -0,0 +1,13 @@
+//go:build !unix
+
+package logger
+
+import (
+	"fmt"
+	"io"
+)
+
+// Syslog is only available on unix platforms
+func openSyslog() (io.Writer, error) {
+	return nil, fmt.Errorf("logging to syslog is not supported on this platform")
+}
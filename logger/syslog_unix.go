@@ -0,0 +1,18 @@
+//go:build unix
+
+package logger
+
+import (
+	"fmt"
+	"io"
+	"log/syslog"
+)
+
+// openSyslog connects to the local syslog daemon
+func openSyslog() (io.Writer, error) {
+	w, err := syslog.New(syslog.LOG_INFO|syslog.LOG_USER, "janus-go")
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to syslog: %w", err)
+	}
+	return w, nil
+}
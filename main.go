@@ -2,28 +2,203 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
 
 	"janus/aws"
+	"janus/cache"
+	"janus/exitcode"
 	"janus/gcp"
+	"janus/identity"
 	"janus/logger"
+	"janus/output"
 	"janus/types"
 )
 
+const (
+	commandServe = "serve" // Runs the container credentials endpoint
+	commandIMDS  = "imds"  // Runs the EC2 instance metadata service emulation
+	commandExec  = "exec"  // Runs a command with credentials in its environment
+)
+
+// errorFormat is the format failures are reported in, set from the -errorformat flag
+var errorFormat = types.ErrorFormatText
+
+// options holds command line flags shared by all commands
+type options struct {
+	showVersion        *bool
+	awsAssumeRoleArn   *string
+	printIdToken       *bool
+	stsRegion          *string
+	stsEndpoint        *string
+	useFIPS            *bool
+	useDualStack       *bool
+	sessionId          *string
+	sessionTemplate    *string
+	audience           *string
+	provider           *string
+	tokenFile          *string
+	impersonate        *string
+	delegates          stringSliceFlag
+	duration           *time.Duration
+	roleChain          roleChainFlag
+	policy             *string
+	policyArns         stringSliceFlag
+	sourceIdentity     *string
+	tags               sessionTagFlag
+	metadataTags       metadataTagFlag
+	transitiveTags     stringSliceFlag
+	logLevel           *string
+	logFormat          *string
+	logOutput          *string
+	useCache           *bool
+	cacheDir           *string
+	cacheRefreshWindow *time.Duration
+	retries            *int
+	retryDelay         *time.Duration
+	timeout            *time.Duration
+	errorFormat        *string
+	configFile         *string
+	profile            *string
+	// outputFormat is only registered by commands which print credentials
+	outputFormat *string
+}
+
 func main() {
-	showVersion := flag.Bool("version", false, "Print version information")
-	awsAssumeRoleArn := flag.String("rolearn", "", "AWS role ARN to assume (required)")
-	printIdToken := flag.Bool("printidtoken", false, "Print Google identity token when log level is DEBUG")
-	stsRegion := flag.String("stsregion", types.STSRegionDefault, "AWS STS region to which requests are made (optional)")
-	sessionId := flag.String("sessionid", "", "AWS session identifier (optional) (defaults AWS_SESSION_IDENTIFIER or GCP metadata)")
-	logLevel := flag.String("loglevel", "ERROR", "Logging level (DEBUG, INFO, WARN, ERROR)")
+	command, args := "", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "":
+		runCredentialProcess(args)
+	case commandServe:
+		runServe(args)
+	case commandIMDS:
+		runIMDS(args)
+	case commandExec:
+		runExec(args)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q (available commands: %s, %s, %s)\n", command, commandServe, commandIMDS, commandExec)
+		os.Exit(exitcode.Code(exitcode.ClassUsage))
+	}
+}
+
+// runCredentialProcess prints credentials in the format expected by AWS credential_process
+func runCredentialProcess(args []string) {
+	fs, opts := newFlagSet(os.Args[0])
+	opts.outputFormat = fs.String("output", types.OutputJSON, "Credentials output format (json, env, fish, powershell, dotenv)")
+	writeProfile := fs.String("writeprofile", "", "Write credentials into this profile of the AWS shared credentials file instead of printing them (optional)")
+	credentialsFile := fs.String("credentialsfile", "", "AWS shared credentials file used with -writeprofile (optional) (defaults to AWS_SHARED_CREDENTIALS_FILE or ~/.aws/credentials)")
+	config := parseConfig(fs, opts, args)
+
+	config.WriteProfile = *writeProfile
+	config.CredentialsFile = *credentialsFile
+	if config.WriteProfile != "" {
+		if err := types.ValidateProfileName(config.WriteProfile); err != nil {
+			exitWithUsage(fs, err)
+		}
+	}
+
+	ctx := context.Background()
 
-	flag.Parse()
+	config, sessionIdentifier := resolveSession(ctx, config)
+
+	credentials, err := fetchCredentials(ctx, config, sessionIdentifier)
+	if err != nil {
+		exitWithError(err)
+	}
+
+	if config.WriteProfile != "" {
+		if err := writeCredentialsProfile(ctx, config, credentials); err != nil {
+			exitWithError(err)
+		}
+		return
+	}
+
+	if err := output.Write(os.Stdout, config.OutputFormat, credentials); err != nil {
+		exitWithError(fmt.Errorf("failed to encode credentials: %w", err))
+	}
+}
+
+// writeCredentialsProfile writes credentials into the configured shared credentials file profile,
+// waiting for other writers of the file no longer than the configured timeout
+func writeCredentialsProfile(ctx context.Context, config types.Config, credentials *types.AWSTempCredentials) error {
+	path := config.CredentialsFile
+	if path == "" {
+		defaultPath, err := output.CredentialsFilePath()
+		if err != nil {
+			return err
+		}
+		path = defaultPath
+	}
+
+	ctx, cancel := withTimeout(ctx, config)
+	defer cancel()
+
+	if err := output.WriteProfile(ctx, path, config.WriteProfile, credentials); err != nil {
+		return err
+	}
+	logger.Logger.Info("Wrote AWS credentials to shared credentials file", "path", path, "profile", config.WriteProfile, "expiration", credentials.Expiration)
+	return nil
+}
 
-	if *showVersion {
+// newFlagSet creates a flag set with the flags shared by all commands
+func newFlagSet(name string) (*flag.FlagSet, *options) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	opts := &options{}
+
+	opts.showVersion = fs.Bool("version", false, "Print version information")
+	opts.awsAssumeRoleArn = fs.String("rolearn", "", "AWS role ARN to assume (required)")
+	opts.printIdToken = fs.Bool("printidtoken", false, "Print Google identity token claims (iss, aud, sub, email, exp) when log level is DEBUG")
+	opts.stsRegion = fs.String("stsregion", types.STSRegionDefault, "AWS STS region to which requests are made (optional)")
+	opts.stsEndpoint = fs.String("stsendpoint", "", "AWS STS endpoint URL, such as a VPC interface endpoint, used instead of the regional endpoint (optional)")
+	opts.useFIPS = fs.Bool("fips", false, "Use the FIPS endpoint of the AWS STS region")
+	opts.useDualStack = fs.Bool("dualstack", false, "Use the dual-stack (IPv4 and IPv6) endpoint of the AWS STS region")
+	opts.sessionId = fs.String("sessionid", "", "AWS session identifier (optional) (defaults AWS_SESSION_IDENTIFIER or GCP metadata)")
+	opts.sessionTemplate = fs.String("sessiontemplate", "", "AWS session identifier template such as {{.Project}}-{{.Pod}}-{{.Hash}}, used when no session identifier is given (optional)")
+	opts.audience = fs.String("audience", "", "Identity token audience (optional) (defaults IDENTITY_TOKEN_AUDIENCE, gcp for Google or sts.amazonaws.com for GitHub, required for Azure)")
+	opts.provider = fs.String("provider", types.ProviderAuto, "Identity token provider: auto, gcp, file, github, gitlab or azure (optional)")
+	opts.tokenFile = fs.String("tokenfile", "", "File containing the identity token, re-read on every use, instead of Google credentials (optional) (defaults JANUS_TOKEN_FILE)")
+	opts.impersonate = fs.String("impersonate", "", "Service account email to impersonate when minting the Google identity token (optional)")
+	fs.Var(&opts.delegates, "delegate", "Service account email in the impersonation delegate chain, may be repeated (optional)")
+	opts.duration = fs.Duration("duration", 0, "AWS role session duration between 15m and 12h (optional) (defaults to STS default of 1h)")
+	fs.Var(&opts.roleChain, "chain", "Role to assume with the previous role's credentials as ARN[,sessionname=NAME][,externalid=ID][,duration=DURATION], may be repeated (optional)")
+	opts.policy = fs.String("policy", "", "Inline JSON session policy, or @file to read it from a file (optional)")
+	fs.Var(&opts.policyArns, "policyarn", "Managed session policy ARN, may be repeated (optional)")
+	opts.sourceIdentity = fs.String("sourceidentity", "", "Source identity set on the first chained role, a template over the -sessiontemplate fields such as {{.Email}} (optional)")
+	fs.Var(&opts.tags, "tag", "Session tag as KEY=VALUE set on the first chained role, may be repeated (optional)")
+	fs.Var(&opts.metadataTags, "metadatatag", "Session tag as KEY=SOURCE with the value of project-id, zone, cluster-name, instance-name, pod, namespace or service-account, may be repeated (optional)")
+	fs.Var(&opts.transitiveTags, "transitivetag", "Session tag key which persists through further role chaining, may be repeated (optional)")
+	opts.logLevel = fs.String("loglevel", "ERROR", "Logging level (DEBUG, INFO, WARN, ERROR)")
+	opts.logFormat = fs.String("logformat", types.LogFormatJSON, "Log record format (json, text)")
+	opts.logOutput = fs.String("logoutput", types.LogOutputStderr, "Log destination: stderr, syslog or a file path (logs never go to stdout)")
+	opts.useCache = fs.Bool("cache", false, "Cache credentials on disk and reuse them until they are about to expire")
+	opts.cacheDir = fs.String("cachedir", "", "Directory for cached credentials (optional) (defaults to user cache directory)")
+	opts.cacheRefreshWindow = fs.Duration("cacherefresh", types.CacheRefreshWindowDefault, "Refresh cached credentials this long before they expire")
+	opts.retries = fs.Int("retries", types.RetriesDefault, "Retries after transient GCP metadata, identity token and AWS STS failures")
+	opts.retryDelay = fs.Duration("retrydelay", types.RetryDelayDefault, "Backoff before the first retry, doubled for every further retry with random jitter")
+	opts.timeout = fs.Duration("timeout", 0, "Deadline for fetching credentials including all retries (optional) (defaults to no deadline)")
+	opts.errorFormat = fs.String("errorformat", types.ErrorFormatText, "Error reporting format: text logs errors, json writes an object with exit code, class and hint to stderr")
+	opts.configFile = fs.String("config", "", "Configuration file with named profiles (optional) (defaults to JANUS_CONFIG or janus-go/config.yaml in user config directory)")
+	opts.profile = fs.String("profile", "", "Configuration file profile to use, flags override profile settings (optional)")
+
+	return fs, opts
+}
+
+// parseConfig parses command line arguments, initializes the logger and returns the validated
+// configuration. It exits the program when the version is requested or arguments are invalid.
+func parseConfig(fs *flag.FlagSet, opts *options, args []string) types.Config {
+	// The flag set exits on parse errors
+	_ = fs.Parse(args)
+
+	if *opts.showVersion {
 		fmt.Printf("Version: %s\nCommit: %s\nBuilt: %s\n",
 			types.Version,
 			types.Commit,
@@ -31,52 +206,361 @@ func main() {
 		os.Exit(0)
 	}
 
-	logger.InitLogger(*logLevel)
+	if err := logger.Init(logger.Options{Level: *opts.logLevel, Format: *opts.logFormat, Output: *opts.logOutput}); err != nil {
+		exitWithUsage(fs, err)
+	}
+
+	if err := types.ValidateErrorFormat(*opts.errorFormat); err != nil {
+		exitWithUsage(fs, err)
+	}
+	errorFormat = *opts.errorFormat
+
+	if err := applyProfile(fs, opts); err != nil {
+		exitWithError(exitcode.Wrap(exitcode.ClassUsage, err))
+	}
 
 	config := types.Config{
-		PrintIdToken: *printIdToken,
-		LogLevel:     *logLevel,
+		PrintIdToken:        *opts.printIdToken,
+		LogLevel:            *opts.logLevel,
+		RoleArn:             *opts.awsAssumeRoleArn,
+		STSRegion:           *opts.stsRegion,
+		STSEndpoint:         *opts.stsEndpoint,
+		UseFIPS:             *opts.useFIPS,
+		UseDualStack:        *opts.useDualStack,
+		SessionID:           *opts.sessionId,
+		SessionNameTemplate: *opts.sessionTemplate,
+		Audience:            *opts.audience,
+		Provider:            *opts.provider,
+		TokenFile:           *opts.tokenFile,
+		Impersonate:         *opts.impersonate,
+		Delegates:           opts.delegates,
+		Duration:            *opts.duration,
+		Chain:               opts.roleChain,
+		PolicyARNs:          opts.policyArns,
+		SourceIdentity:      *opts.sourceIdentity,
+		Tags:                opts.tags,
+		MetadataTags:        opts.metadataTags,
+		TransitiveTagKeys:   opts.transitiveTags,
+		Cache:               *opts.useCache,
+		CacheDir:            *opts.cacheDir,
+		CacheRefreshWindow:  *opts.cacheRefreshWindow,
+		Retries:             *opts.retries,
+		RetryDelay:          *opts.retryDelay,
+		Timeout:             *opts.timeout,
+	}
+	if opts.outputFormat != nil {
+		config.OutputFormat = *opts.outputFormat
 	}
 
-	if err := types.ValidateRoleArn(*awsAssumeRoleArn); err != nil {
-		logger.Logger.Error(err.Error())
-		flag.Usage()
-		os.Exit(1)
+	if err := validateConfig(config); err != nil {
+		exitWithUsage(fs, err)
+	}
+
+	sessionPolicy, err := loadPolicy(*opts.policy)
+	if err != nil {
+		exitWithError(exitcode.Wrap(exitcode.ClassUsage, err))
 	}
-	if err := types.ValidateSTSRegion(*stsRegion); err != nil {
-		logger.Logger.Error(err.Error())
-		flag.Usage()
-		os.Exit(1)
+	config.Policy = sessionPolicy
+	if err := types.ValidateSessionPolicies(config.Policy, config.PolicyARNs); err != nil {
+		exitWithError(exitcode.Wrap(exitcode.ClassUsage, err))
 	}
 
-	ctx := context.Background()
+	return config
+}
+
+// exitWithError reports err and exits the program with the exit code of its class
+func exitWithError(err error) {
+	class := exitcode.Classify(err)
+	if errorFormat == types.ErrorFormatJSON {
+		_ = exitcode.WriteJSON(os.Stderr, err)
+	} else {
+		logger.Logger.Error(err.Error(), "class", class)
+	}
+	os.Exit(exitcode.Code(class))
+}
+
+// exitWithUsage reports an invalid invocation, printing the usage unless errors are reported
+// as JSON, and exits the program
+func exitWithUsage(fs *flag.FlagSet, err error) {
+	if errorFormat == types.ErrorFormatJSON {
+		exitWithError(exitcode.Wrap(exitcode.ClassUsage, err))
+	}
 
-	gcpMetadataClient := gcp.NewMetadataClient(ctx)
+	logger.Logger.Error(err.Error(), "class", exitcode.ClassUsage)
+	fs.Usage()
+	os.Exit(exitcode.Code(exitcode.ClassUsage))
+}
 
-	sessionIdentifier, err := gcp.GetSessionIdentifier(ctx, *sessionId, gcpMetadataClient)
+// validateConfig validates the role, STS and output settings of the configuration
+func validateConfig(config types.Config) error {
+	if err := types.ValidateRoleArn(config.RoleArn); err != nil {
+		return err
+	}
+	if err := types.ValidateSTSRegion(config.STSRegion); err != nil {
+		return err
+	}
+	if config.STSEndpoint != "" {
+		if err := types.ValidateSTSEndpoint(config.STSEndpoint); err != nil {
+			return err
+		}
+		// The SDK rejects FIPS and dual-stack endpoint resolution together with a custom endpoint
+		if config.UseFIPS || config.UseDualStack {
+			return fmt.Errorf("-fips and -dualstack cannot be used with -stsendpoint, give the FIPS or dual-stack endpoint URL instead")
+		}
+	} else if config.UseFIPS {
+		if err := types.ValidateSTSFIPSRegion(config.STSRegion); err != nil {
+			return err
+		}
+	}
+	if err := types.ValidateSTSPartition(config.RoleArn, config.STSRegion, config.STSEndpoint); err != nil {
+		return err
+	}
+	if err := types.ValidateDuration(config.Duration); err != nil {
+		return err
+	}
+	if err := types.ValidateRetries(config.Retries, config.RetryDelay); err != nil {
+		return err
+	}
+	if config.Timeout < 0 {
+		return fmt.Errorf("invalid timeout: %s (must not be negative)", config.Timeout)
+	}
+	for _, hop := range config.Chain {
+		if err := types.ValidateRoleHop(hop); err != nil {
+			return err
+		}
+		if err := types.ValidateSTSPartition(hop.RoleArn, config.STSRegion, config.STSEndpoint); err != nil {
+			return err
+		}
+	}
+	if err := types.ValidateProvider(config.Provider); err != nil {
+		return err
+	}
+	providerName := identity.ProviderName(config)
+	if config.TokenFile != "" && providerName != types.ProviderFile {
+		return fmt.Errorf("-tokenfile cannot be used with the %s identity provider", providerName)
+	}
+	if config.Impersonate != "" && providerName != types.ProviderGCP {
+		return fmt.Errorf("-impersonate requires the %s identity provider", types.ProviderGCP)
+	}
+	if providerName == types.ProviderAzure && identity.Audience(config) == "" {
+		return fmt.Errorf("the %s identity provider requires -audience or %s", types.ProviderAzure, types.EnvTokenAudience)
+	}
+	if len(config.Delegates) > 0 && config.Impersonate == "" {
+		return fmt.Errorf("impersonation delegates require a service account to impersonate")
+	}
+	if config.Impersonate != "" {
+		if err := types.ValidateServiceAccountEmail(config.Impersonate); err != nil {
+			return err
+		}
+	}
+	for _, delegate := range config.Delegates {
+		if err := types.ValidateServiceAccountEmail(delegate); err != nil {
+			return err
+		}
+	}
+	if err := types.ValidateSessionTags(config.Tags, config.MetadataTags, config.TransitiveTagKeys); err != nil {
+		return err
+	}
+	// AssumeRoleWithWebIdentity takes session tags and the source identity only from claims
+	// of the identity token, so they are set on a chained AssumeRole call
+	if (len(config.Tags) > 0 || len(config.MetadataTags) > 0) && len(config.Chain) == 0 {
+		return fmt.Errorf("session tags require a chained role (-chain), the web identity role only receives tags from the identity token")
+	}
+	if config.SessionNameTemplate != "" {
+		if _, err := gcp.ParseTemplate("session name", config.SessionNameTemplate); err != nil {
+			return err
+		}
+	}
+	if config.SourceIdentity != "" {
+		if len(config.Chain) == 0 {
+			return fmt.Errorf("source identity requires a chained role (-chain), the web identity role only receives a source identity from the identity token")
+		}
+		if _, err := gcp.ParseTemplate("source identity", config.SourceIdentity); err != nil {
+			return err
+		}
+	}
+	if config.OutputFormat != "" {
+		if err := types.ValidateOutputFormat(config.OutputFormat); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getSessionIdentifier determines the AWS session identifier, exiting the program on failure.
+// GCP metadata is only consulted when identity tokens come from Google or a session name
+// template is given.
+func getSessionIdentifier(ctx context.Context, config types.Config) string {
+	ctx, cancel := withTimeout(ctx, config)
+	defer cancel()
+
+	var gcpMetadataClient *gcp.MetadataClient
+	if identity.ProviderName(config) == types.ProviderGCP || config.SessionNameTemplate != "" {
+		gcpMetadataClient = newMetadataClient(ctx, config)
+	}
+
+	sessionIdentifier, err := gcp.GetSessionIdentifier(ctx, config, gcpMetadataClient)
+	if err != nil {
+		exitWithError(fmt.Errorf("failed to get session identifier: %w", err))
+	}
+	return sessionIdentifier
+}
+
+// tokenRetrieverFunc returns the source of identity tokens used for fetching credentials
+type tokenRetrieverFunc func(ctx context.Context) (stscreds.IdentityTokenRetriever, error)
+
+// fetchCredentials exchanges an identity token for AWS credentials, using the on-disk
+// cache when it is enabled
+func fetchCredentials(ctx context.Context, config types.Config, sessionIdentifier string) (*types.AWSTempCredentials, error) {
+	return fetchCredentialsWith(ctx, config, sessionIdentifier, func(ctx context.Context) (stscreds.IdentityTokenRetriever, error) {
+		return identityTokenRetriever(ctx, config)
+	})
+}
+
+// fetchCredentialsWith exchanges an identity token from the retriever returned by
+// tokenRetriever for AWS credentials, using the on-disk cache when it is enabled
+func fetchCredentialsWith(ctx context.Context, config types.Config, sessionIdentifier string, tokenRetriever tokenRetrieverFunc) (*types.AWSTempCredentials, error) {
+	ctx, cancel := withTimeout(ctx, config)
+	defer cancel()
+
+	return cachedCredentials(ctx, config, credentialsCacheKey(config, sessionIdentifier), func() (*types.AWSTempCredentials, error) {
+		tokenRetriever, err := tokenRetriever(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		return aws.GetCredentials(ctx, config, sessionIdentifier, tokenRetriever)
+	})
+}
+
+// withTimeout applies the configured deadline for fetching credentials to ctx
+func withTimeout(ctx context.Context, config types.Config) (context.Context, context.CancelFunc) {
+	if config.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, config.Timeout)
+}
+
+// identityTokenRetriever returns the source of identity tokens exchanged for AWS credentials
+// from the configured or detected identity provider
+func identityTokenRetriever(ctx context.Context, config types.Config) (stscreds.IdentityTokenRetriever, error) {
+	provider, err := identity.NewProvider(config)
+	if err != nil {
+		return nil, exitcode.Wrap(exitcode.ClassIdentity, err)
+	}
+
+	logger.Logger.Debug("Using identity provider", "provider", provider.Name())
+	tokenSource, err := provider.TokenSource(ctx, config)
+	if err != nil {
+		return nil, exitcode.Wrap(exitcode.ClassIdentity, fmt.Errorf("failed to retrieve %s identity token: %w", provider.Name(), err))
+	}
+
+	return identity.TokenRetriever{TokenSource: tokenSource}, nil
+}
+
+// credentialsCacheKey derives the cache key from every setting that affects the issued credentials
+func credentialsCacheKey(config types.Config, sessionIdentifier string) string {
+	return cache.Key(
+		config.RoleArn,
+		config.STSRegion,
+		config.STSEndpoint,
+		sessionIdentifier,
+		identity.ProviderName(config),
+		identity.Audience(config),
+		identity.TokenFile(config),
+		config.Impersonate,
+		strings.Join(config.Delegates, ","),
+		config.Duration.String(),
+		config.Policy,
+		strings.Join(config.PolicyARNs, ","),
+		fmt.Sprint(config.Chain),
+		config.SourceIdentity,
+		fmt.Sprint(config.Tags),
+		strings.Join(config.TransitiveTagKeys, ","),
+	)
+}
+
+// cacheRefreshWindow returns how long before expiry cached credentials are refreshed. A window
+// at least as long as the session would make every cached entry stale, so it is reduced to half
+// the session duration like the credential servers refresh short-lived credentials halfway.
+func cacheRefreshWindow(config types.Config) time.Duration {
+	duration := sessionDuration(config)
+	if config.CacheRefreshWindow < duration {
+		return config.CacheRefreshWindow
+	}
+	logger.Logger.Debug("Cache refresh window is not shorter than the session duration, refreshing halfway instead", "refreshWindow", config.CacheRefreshWindow, "duration", duration)
+	return duration / 2
+}
+
+// sessionDuration returns the duration of the issued credentials, which is that of the last
+// chained role when role chaining is used, falling back to the defaults of STS and the SDK
+func sessionDuration(config types.Config) time.Duration {
+	if len(config.Chain) > 0 {
+		if duration := config.Chain[len(config.Chain)-1].Duration; duration != 0 {
+			return duration
+		}
+		return stscreds.DefaultDuration
+	}
+	if config.Duration != 0 {
+		return config.Duration
+	}
+	return types.STSDurationDefault
+}
+
+// cachedCredentials returns credentials from the on-disk cache when caching is enabled and
+// the cached credentials are still fresh. Otherwise it calls fetch and stores the result.
+// Cache failures are logged and never prevent credentials from being fetched, but waiting for
+// another invocation holding the cache lock counts against the deadline of ctx.
+func cachedCredentials(ctx context.Context, config types.Config, key string, fetch func() (*types.AWSTempCredentials, error)) (*types.AWSTempCredentials, error) {
+	if !config.Cache {
+		return fetch()
+	}
+
+	dir := config.CacheDir
+	if dir == "" {
+		defaultDir, err := cache.DefaultDir()
+		if err != nil {
+			logger.Logger.Warn("Credential cache disabled", "error", err)
+			return fetch()
+		}
+		dir = defaultDir
+	}
+
+	credentialCache, err := cache.New(dir, cacheRefreshWindow(config))
 	if err != nil {
-		logger.Logger.Error(fmt.Errorf("failed to get session identifier: %w", err).Error())
-		os.Exit(1)
+		logger.Logger.Warn("Credential cache disabled", "error", err)
+		return fetch()
 	}
 
-	gcpMetadataTokenSource, err := gcp.TokenSource(ctx, config)
+	lock, err := credentialCache.Lock(ctx, key)
 	if err != nil {
-		logger.Logger.Error(fmt.Errorf("failed to retrieve GCP identity token: %w", err).Error())
-		os.Exit(1)
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		logger.Logger.Warn("Credential cache disabled", "error", err)
+		return fetch()
 	}
+	defer func() {
+		if err := lock.Unlock(); err != nil {
+			logger.Logger.Warn("Failed to release credential cache lock", "error", err)
+		}
+	}()
 
-	gcpMetadataToken := gcp.CustomIdentityTokenRetriever{TokenSource: gcpMetadataTokenSource}
+	cached, err := credentialCache.Get(key, time.Now())
+	if err != nil {
+		logger.Logger.Warn("Ignoring unreadable cached credentials", "error", err)
+	} else if cached != nil {
+		logger.Logger.Debug("Using cached AWS credentials", "expiration", cached.Expiration)
+		return cached, nil
+	}
 
-	credentials, err := aws.GetCredentials(ctx, *stsRegion, *awsAssumeRoleArn, sessionIdentifier, gcpMetadataToken)
+	credentials, err := fetch()
 	if err != nil {
-		logger.Logger.Error(err.Error())
-		os.Exit(1)
+		return nil, err
 	}
 
-	// AWS CLI config credential_process requires JSON output containing credentials
-	// and expiration time
-	if err := json.NewEncoder(os.Stdout).Encode(credentials); err != nil {
-		logger.Logger.Error(fmt.Errorf("failed to encode credentials: %w", err).Error())
-		os.Exit(1)
+	if err := credentialCache.Put(key, credentials); err != nil {
+		logger.Logger.Warn("Failed to cache AWS credentials", "error", err)
 	}
+	return credentials, nil
 }
@@ -1,9 +1,20 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
 	"testing"
 	"time"
 
@@ -12,7 +23,9 @@ import (
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 
+	"janus/cache"
 	"janus/gcp"
+	"janus/identity"
 	"janus/logger"
 	"janus/types"
 )
@@ -33,6 +46,115 @@ func init() {
 	logger.InitLogger("ERROR")
 }
 
+// envRunMain makes the test binary run main instead of the tests, so that tests can run
+// janus-go as a subprocess
+const envRunMain = "JANUS_TEST_RUN_MAIN"
+
+const (
+	// envRunChild makes the test binary run itself through runChild with debug logging,
+	// counting signals into the given file
+	envRunChild = "JANUS_TEST_RUN_CHILD"
+	// envCountSignals makes the test binary count the interrupts it receives into the given file
+	envCountSignals = "JANUS_TEST_COUNT_SIGNALS"
+)
+
+func TestMain(m *testing.M) {
+	if os.Getenv(envRunMain) == "1" {
+		main()
+		os.Exit(0)
+	}
+	if path := os.Getenv(envRunChild); path != "" {
+		logger.InitLogger("DEBUG")
+		env := append(os.Environ(), envRunChild+"=", envCountSignals+"="+path)
+		os.Exit(runChild([]string{os.Args[0]}, env))
+	}
+	if path := os.Getenv(envCountSignals); path != "" {
+		countSignals(path)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// countSignals creates path.ready once interrupts are caught, then writes the number of
+// interrupts received within half a second of the first one to path
+func countSignals(path string) {
+	signals := make(chan os.Signal, 8)
+	signal.Notify(signals, os.Interrupt)
+	if err := os.WriteFile(path+".ready", nil, 0o600); err != nil {
+		os.Exit(1)
+	}
+
+	<-signals
+	count := 1
+	timeout := time.After(500 * time.Millisecond)
+	for {
+		select {
+		case <-signals:
+			count++
+		case <-timeout:
+			_ = os.WriteFile(path, []byte(strconv.Itoa(count)), 0o600)
+			return
+		}
+	}
+}
+
+// runMain runs janus-go with the given arguments in a subprocess and returns its stdout and stderr
+func runMain(t *testing.T, env []string, args ...string) (string, string, error) {
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(append(os.Environ(), envRunMain+"=1"), env...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	return stdout.String(), stderr.String(), err
+}
+
+// stsServer is a stand-in for AWS STS answering AssumeRoleWithWebIdentity
+type stsServer struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	tokens []string
+}
+
+// webIdentityTokens returns the identity tokens of the requests received so far
+func (s *stsServer) webIdentityTokens() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.tokens)
+}
+
+// newSTSServer starts a stand-in for AWS STS answering AssumeRoleWithWebIdentity
+func newSTSServer(t *testing.T) *stsServer {
+	sts := &stsServer{}
+	sts.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sts.mu.Lock()
+		sts.tokens = append(sts.tokens, r.FormValue("WebIdentityToken"))
+		sts.mu.Unlock()
+
+		w.Header().Set("Content-Type", "text/xml")
+		fmt.Fprint(w, `<AssumeRoleWithWebIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleWithWebIdentityResult>
+    <Credentials>
+      <AccessKeyId>ASIAMOCKACCESSKEY</AccessKeyId>
+      <SecretAccessKey>mock-secret-access-key</SecretAccessKey>
+      <SessionToken>mock-session-token</SessionToken>
+      <Expiration>2099-01-01T00:00:00Z</Expiration>
+    </Credentials>
+    <AssumedRoleUser>
+      <Arn>arn:aws:sts::123456789012:assumed-role/my-trusted-role/janus</Arn>
+      <AssumedRoleId>AROAMOCK:janus</AssumedRoleId>
+    </AssumedRoleUser>
+  </AssumeRoleWithWebIdentityResult>
+  <ResponseMetadata>
+    <RequestId>mock-request-id</RequestId>
+  </ResponseMetadata>
+</AssumeRoleWithWebIdentityResponse>`)
+	}))
+	t.Cleanup(sts.Close)
+	return sts
+}
+
 // MockGCPMetadataServer creates and returns a mock GCP metadata server.
 func MockGCPMetadataServer(tokenSource *oauth2.TokenSource) *mds.MetadataServer {
 	ctx := context.Background()
@@ -104,7 +226,7 @@ func TestCreateSessionIdentifier(t *testing.T) {
 		t.Errorf("Unexpected error: %v", err)
 	}
 
-	expectedSessionID := fmt.Sprintf("%s-%s", gcpProjectID, gceInstanceHostname)[:32]
+	expectedSessionID := fmt.Sprintf("%s-%s", gcpProjectID, gceInstanceHostname)
 	if sessionID != expectedSessionID {
 		t.Errorf("Unexpected session ID: got %s, want %s", sessionID, expectedSessionID)
 	}
@@ -206,7 +328,7 @@ func TestContextCancellation(t *testing.T) {
 	}
 
 	// Now try to get session identifier
-	_, err = gcp.GetSessionIdentifier(ctx, "", client)
+	_, err = gcp.GetSessionIdentifier(ctx, types.Config{}, client)
 	if err == nil {
 		t.Error("Expected error when getting session identifier with cancelled context, got nil")
 	} else if ctx.Err() != err {
@@ -214,6 +336,67 @@ func TestContextCancellation(t *testing.T) {
 	}
 }
 
+// TestSessionIdentifierTemplate verifies that session identifiers are rendered from GCP metadata
+// and downward API fields
+func TestSessionIdentifierTemplate(t *testing.T) {
+	_, cleanup := setupMockServer(t)
+	defer cleanup()
+	t.Setenv(types.EnvSessionID, "")
+	t.Setenv(types.EnvPodName, "checkout-7d9f8b6c5d-x2x4z")
+
+	ctx := context.Background()
+	config := types.Config{SessionNameTemplate: "{{.Project}}/{{.Pod}}-{{.Hash}}"}
+
+	sessionId, err := gcp.GetSessionIdentifier(ctx, config, gcp.NewMetadataClient(ctx))
+	assert.NoError(t, err)
+	assert.Regexp(t, `^janus-go-checkout-7d9f8b6c5d-x2x4z-[0-9a-f]{8}$`, sessionId)
+
+	again, err := gcp.GetSessionIdentifier(ctx, config, gcp.NewMetadataClient(ctx))
+	assert.NoError(t, err)
+	assert.Equal(t, sessionId, again, "Hash must be stable")
+
+	config.SessionNameTemplate = "{{.Project}}-{{.Node}}"
+	_, err = gcp.GetSessionIdentifier(ctx, config, gcp.NewMetadataClient(ctx))
+	assert.ErrorContains(t, err, types.EnvNodeName, "Templates do not fall back to the hostname")
+}
+
+// TestSessionIdentifierKubernetes verifies that pods are told apart by namespace, service account
+// and pod name, falling back to the hostname when the pod identity is incomplete
+func TestSessionIdentifierKubernetes(t *testing.T) {
+	t.Setenv(types.EnvSessionID, "")
+	t.Setenv(types.EnvKubernetesServiceHost, "10.0.0.1")
+	t.Setenv(types.EnvPodName, "checkout-7d9f8b6c5d-x2x4z")
+	t.Setenv(types.EnvPodNamespace, "payments")
+	t.Setenv(types.EnvPodServiceAccount, "checkout")
+
+	sessionId, err := gcp.GetSessionIdentifier(context.Background(), types.Config{}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "payments-checkout-checkout-7d9f8b6c5d-x2x4z", sessionId)
+
+	if _, err := os.Stat("/var/run/secrets/kubernetes.io/serviceaccount/token"); err == nil {
+		t.Skip("service account token is mounted, the fallback cannot be tested")
+	}
+	hostname, err := os.Hostname()
+	assert.NoError(t, err)
+	t.Setenv(types.EnvPodServiceAccount, "")
+	sessionId, err = gcp.GetSessionIdentifier(context.Background(), types.Config{}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, hostname, sessionId)
+}
+
+// TestSessionIdentifierWithoutMetadata verifies that the hostname is used when GCP metadata is skipped
+func TestSessionIdentifierWithoutMetadata(t *testing.T) {
+	t.Setenv(types.EnvSessionID, "")
+	t.Setenv(types.EnvKubernetesServiceHost, "")
+
+	hostname, err := os.Hostname()
+	assert.NoError(t, err)
+
+	sessionId, err := gcp.GetSessionIdentifier(context.Background(), types.Config{}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, hostname, sessionId)
+}
+
 // TestContextTimeout verifies that operations respect context timeout
 func TestContextTimeout(t *testing.T) {
 	_, cleanup := setupMockServer(t)
@@ -238,10 +421,369 @@ func TestContextTimeout(t *testing.T) {
 	}
 
 	// Now try to get session identifier
-	_, err = gcp.GetSessionIdentifier(ctx, "", client)
+	_, err = gcp.GetSessionIdentifier(ctx, types.Config{}, client)
 	if err == nil {
 		t.Error("Expected error when getting session identifier with timed out context, got nil")
 	} else if ctx.Err() != err {
 		t.Errorf("Expected context.DeadlineExceeded error, got: %v", err)
 	}
 }
+
+// TestFetchCredentialsCacheLockTimeout verifies that waiting for a held cache lock stops at the timeout
+func TestFetchCredentialsCacheLockTimeout(t *testing.T) {
+	config := types.Config{
+		RoleArn:   "arn:aws:iam::123456789012:role/my-trusted-role",
+		STSRegion: types.STSRegionDefault,
+		Cache:     true,
+		CacheDir:  t.TempDir(),
+		Timeout:   100 * time.Millisecond,
+	}
+
+	credentialCache, err := cache.New(config.CacheDir, 0)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	lock, err := credentialCache.Lock(context.Background(), credentialsCacheKey(config, "janus"))
+	if err != nil {
+		t.Fatalf("Failed to acquire lock: %v", err)
+	}
+	defer lock.Unlock()
+
+	start := time.Now()
+	_, err = fetchCredentials(context.Background(), config, "janus")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 2*time.Second, "Waiting for the cache lock should respect the timeout")
+}
+
+// TestCacheRefreshWindow verifies that refresh windows not shorter than the session are reduced
+func TestCacheRefreshWindow(t *testing.T) {
+	tests := []struct {
+		name   string
+		config types.Config
+		want   time.Duration
+	}{
+		{
+			name:   "shorter than default session",
+			config: types.Config{CacheRefreshWindow: 15 * time.Minute},
+			want:   15 * time.Minute,
+		},
+		{
+			name:   "equal to session duration",
+			config: types.Config{CacheRefreshWindow: 15 * time.Minute, Duration: 15 * time.Minute},
+			want:   7*time.Minute + 30*time.Second,
+		},
+		{
+			name:   "longer than session duration",
+			config: types.Config{CacheRefreshWindow: 2 * time.Hour, Duration: time.Hour},
+			want:   30 * time.Minute,
+		},
+		{
+			name: "chained role default duration",
+			config: types.Config{
+				CacheRefreshWindow: 15 * time.Minute,
+				Duration:           time.Hour,
+				Chain:              []types.RoleHop{{RoleArn: "arn:aws:iam::210987654321:role/workload"}},
+			},
+			want: 7*time.Minute + 30*time.Second,
+		},
+		{
+			name: "chained role duration",
+			config: types.Config{
+				CacheRefreshWindow: 15 * time.Minute,
+				Chain:              []types.RoleHop{{RoleArn: "arn:aws:iam::210987654321:role/workload", Duration: time.Hour}},
+			},
+			want: 15 * time.Minute,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, cacheRefreshWindow(tt.config))
+		})
+	}
+}
+
+// TestLoadPolicy verifies inline and file based session policies are compacted
+func TestLoadPolicy(t *testing.T) {
+	policyFile := filepath.Join(t.TempDir(), "policy.json")
+	if err := os.WriteFile(policyFile, []byte("{\n  \"Version\": \"2012-10-17\"\n}\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write policy file: %v", err)
+	}
+
+	policy, err := loadPolicy(`{ "Version": "2012-10-17" }`)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"Version":"2012-10-17"}`, policy, "Inline policy should be compacted")
+
+	policy, err = loadPolicy("@" + policyFile)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"Version":"2012-10-17"}`, policy, "File policy should be compacted")
+
+	_, err = loadPolicy("@" + filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err, "Missing policy file should fail")
+
+	_, err = loadPolicy(`{"Version":`)
+	assert.Error(t, err, "Invalid JSON should fail")
+}
+
+// TestParseRoleHop verifies parsing of chained role specifications
+func TestParseRoleHop(t *testing.T) {
+	hop, err := parseRoleHop("arn:aws:iam::123456789012:role/Target")
+	assert.NoError(t, err)
+	assert.Equal(t, types.RoleHop{RoleArn: "arn:aws:iam::123456789012:role/Target"}, hop)
+
+	hop, err = parseRoleHop("arn:aws:iam::123456789012:role/Target,sessionname=deploy,externalid=abc123,duration=30m")
+	assert.NoError(t, err)
+	assert.Equal(t, types.RoleHop{
+		RoleArn:     "arn:aws:iam::123456789012:role/Target",
+		SessionName: "deploy",
+		ExternalID:  "abc123",
+		Duration:    30 * time.Minute,
+	}, hop)
+
+	_, err = parseRoleHop("arn:aws:iam::123456789012:role/Target,duration=soon")
+	assert.Error(t, err, "Invalid duration should fail")
+
+	_, err = parseRoleHop("arn:aws:iam::123456789012:role/Target,region=us-east-1")
+	assert.Error(t, err, "Unknown option should fail")
+
+	_, err = parseRoleHop("arn:aws:iam::123456789012:role/Target,sessionname")
+	assert.Error(t, err, "Option without value should fail")
+}
+
+// TestChildEnvironment verifies credentials replace existing AWS credentials in the child environment
+func TestChildEnvironment(t *testing.T) {
+	credentials := &types.AWSTempCredentials{
+		Version:         1,
+		AccessKeyId:     "access_key",
+		SecretAccessKey: "secret_key",
+		SessionToken:    "session_token",
+		Expiration:      time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC),
+	}
+
+	env := childEnvironment([]string{
+		"PATH=/usr/bin",
+		"AWS_ACCESS_KEY_ID=old_key",
+		"AWS_SECURITY_TOKEN=old_token",
+	}, credentials, "eu-west-1")
+
+	assert.ElementsMatch(t, []string{
+		"PATH=/usr/bin",
+		"AWS_ACCESS_KEY_ID=access_key",
+		"AWS_SECRET_ACCESS_KEY=secret_key",
+		"AWS_SESSION_TOKEN=session_token",
+		"AWS_CREDENTIAL_EXPIRATION=2026-10-16T12:00:00Z",
+		"AWS_REGION=eu-west-1",
+		"AWS_DEFAULT_REGION=eu-west-1",
+	}, env)
+
+	env = childEnvironment([]string{"AWS_REGION=us-west-2"}, credentials, "eu-west-1")
+	assert.Contains(t, env, "AWS_REGION=us-west-2", "Existing region should be preserved")
+	assert.NotContains(t, env, "AWS_REGION=eu-west-1")
+}
+
+// TestRunChild verifies the child's exit code is propagated
+func TestRunChild(t *testing.T) {
+	assert.Equal(t, 0, runChild([]string{"sh", "-c", "exit 0"}, os.Environ()))
+	assert.Equal(t, 3, runChild([]string{"sh", "-c", "exit 3"}, os.Environ()))
+	assert.Equal(t, 128+15, runChild([]string{"sh", "-c", "kill -TERM $$"}, os.Environ()), "Signal termination should map to 128+signal")
+	assert.Equal(t, exitCommandNotStarted, runChild([]string{"janus-go-command-that-does-not-exist"}, os.Environ()))
+	assert.Equal(t, 0, runChild([]string{"sh", "-c", `test "$JANUS_TEST" = injected`}, append(os.Environ(), "JANUS_TEST=injected")), "Environment should be passed to child")
+}
+
+// TestApplyProfile verifies profile settings apply to flags not set on the command line
+func TestApplyProfile(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	config := `
+profiles:
+  production:
+    role_arn: arn:aws:iam::123456789012:role/profile-role
+    sts_region: eu-west-1
+    duration: 2h
+    output: env
+    chain:
+      - role_arn: arn:aws:iam::210987654321:role/workload
+        duration: 30m
+`
+	if err := os.WriteFile(configFile, []byte(config), 0o600); err != nil {
+		t.Fatalf("Failed to write configuration file: %v", err)
+	}
+	t.Setenv(types.EnvConfigFile, configFile)
+
+	fs, opts := newFlagSet("test")
+	opts.outputFormat = fs.String("output", types.OutputJSON, "")
+	if err := fs.Parse([]string{"-profile", "production", "-stsregion", "us-west-2"}); err != nil {
+		t.Fatalf("Failed to parse flags: %v", err)
+	}
+
+	if err := applyProfile(fs, opts); err != nil {
+		t.Fatalf("applyProfile() error = %v", err)
+	}
+
+	assert.Equal(t, "arn:aws:iam::123456789012:role/profile-role", *opts.awsAssumeRoleArn)
+	assert.Equal(t, "us-west-2", *opts.stsRegion, "Flag should override profile")
+	assert.Equal(t, 2*time.Hour, *opts.duration)
+	assert.Equal(t, types.OutputEnv, *opts.outputFormat)
+	assert.Equal(t, roleChainFlag{{RoleArn: "arn:aws:iam::210987654321:role/workload", Duration: 30 * time.Minute}}, opts.roleChain)
+
+	fs, opts = newFlagSet("test")
+	if err := fs.Parse([]string{"-profile", "missing"}); err != nil {
+		t.Fatalf("Failed to parse flags: %v", err)
+	}
+	assert.Error(t, applyProfile(fs, opts), "Unknown profile should fail")
+}
+
+// TestIdentityTokenAudience verifies audience precedence of configuration, environment and default
+func TestIdentityTokenAudience(t *testing.T) {
+	config := types.Config{Provider: types.ProviderGCP}
+	t.Setenv(types.EnvTokenAudience, "")
+	assert.Equal(t, types.GCPTokenAudience, identity.Audience(config))
+
+	t.Setenv(types.EnvTokenAudience, "env-audience")
+	assert.Equal(t, "env-audience", identity.Audience(config))
+	config.Audience = "flag-audience"
+	assert.Equal(t, "flag-audience", identity.Audience(config))
+}
+
+// mockIdentityToken builds an unsigned JWT expiring at the given time
+func mockIdentityToken(subject string, expiry time.Time) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"aud":"gcp","sub":%q,"exp":%d}`, subject, expiry.Unix())))
+	return header + "." + claims + ".signature"
+}
+
+// TestTokenSourceExpiry verifies that identity tokens from the metadata server carry the expiry of their exp claim
+func TestTokenSourceExpiry(t *testing.T) {
+	expiry := time.Now().Add(time.Hour).Truncate(time.Second)
+	t.Setenv("GOOGLE_ID_TOKEN", mockIdentityToken("first", expiry))
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "")
+
+	_, cleanup := setupMockServer(t)
+	defer cleanup()
+
+	tokenSource, err := gcp.TokenSource(context.Background(), types.Config{}, types.GCPTokenAudience)
+	assert.NoError(t, err)
+
+	token, err := tokenSource.Token()
+	assert.NoError(t, err)
+	assert.Equal(t, mockIdentityToken("first", expiry), token.AccessToken)
+	assert.True(t, token.Expiry.Equal(expiry), "expiry %v, want %v", token.Expiry, expiry)
+
+	// A valid token is reused instead of being fetched again
+	t.Setenv("GOOGLE_ID_TOKEN", mockIdentityToken("second", expiry))
+	token, err = tokenSource.Token()
+	assert.NoError(t, err)
+	assert.Equal(t, mockIdentityToken("first", expiry), token.AccessToken)
+}
+
+// TestTokenSourceRefresh verifies that identity tokens close to expiry are fetched again
+func TestTokenSourceRefresh(t *testing.T) {
+	expiring := time.Now().Add(30 * time.Second)
+	t.Setenv("GOOGLE_ID_TOKEN", mockIdentityToken("expiring", expiring))
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "")
+
+	_, cleanup := setupMockServer(t)
+	defer cleanup()
+
+	tokenSource, err := gcp.TokenSource(context.Background(), types.Config{}, types.GCPTokenAudience)
+	assert.NoError(t, err)
+
+	fresh := time.Now().Add(time.Hour)
+	t.Setenv("GOOGLE_ID_TOKEN", mockIdentityToken("fresh", fresh))
+	token, err := tokenSource.Token()
+	assert.NoError(t, err)
+	assert.Equal(t, mockIdentityToken("fresh", fresh), token.AccessToken)
+}
+
+// TestRefreshFetchReusesIdentityToken verifies that refreshes of the credential servers share one
+// identity token source, which keeps working after the context of a refresh is done
+func TestRefreshFetchReusesIdentityToken(t *testing.T) {
+	expiry := time.Now().Add(time.Hour)
+	t.Setenv("GOOGLE_ID_TOKEN", mockIdentityToken("first", expiry))
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "")
+
+	_, cleanup := setupMockServer(t)
+	defer cleanup()
+
+	sts := newSTSServer(t)
+	t.Setenv("AWS_ENDPOINT_URL_STS", sts.URL)
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv(types.EnvSharedCredentialsFile, filepath.Join(t.TempDir(), "credentials"))
+
+	config := types.Config{
+		RoleArn:   "arn:aws:iam::123456789012:role/my-trusted-role",
+		STSRegion: types.STSRegionDefault,
+		Provider:  types.ProviderGCP,
+		Duration:  time.Hour,
+	}
+	fetch := refreshFetch(context.Background(), config, "janus")
+
+	for _, token := range []string{"first", "second"} {
+		t.Setenv("GOOGLE_ID_TOKEN", mockIdentityToken(token, expiry))
+		ctx, cancel := context.WithCancel(context.Background())
+		_, err := fetch(ctx)
+		cancel()
+		assert.NoError(t, err, "Refresh should not depend on the context of an earlier refresh")
+	}
+
+	first := mockIdentityToken("first", expiry)
+	assert.Equal(t, []string{first, first}, sts.webIdentityTokens(), "Valid identity token should be reused across refreshes")
+}
+
+// TestCredentialProcessStdout verifies that stdout carries only the credential JSON at every log level
+func TestCredentialProcessStdout(t *testing.T) {
+	sts := newSTSServer(t)
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	assert.NoError(t, os.WriteFile(tokenFile, []byte(mockIdentityToken("janus", time.Now().Add(time.Hour))), 0600))
+
+	env := []string{
+		"AWS_ENDPOINT_URL_STS=" + sts.URL,
+		"AWS_EC2_METADATA_DISABLED=true",
+		"AWS_CONFIG_FILE=" + filepath.Join(t.TempDir(), "config"),
+		"AWS_SHARED_CREDENTIALS_FILE=" + filepath.Join(t.TempDir(), "credentials"),
+	}
+
+	for _, level := range []string{"DEBUG", "INFO", "WARN", "ERROR"} {
+		t.Run(level, func(t *testing.T) {
+			stdout, stderr, err := runMain(t, env,
+				"-rolearn", "arn:aws:iam::123456789012:role/my-trusted-role",
+				"-sessionid", "janus",
+				"-tokenfile", tokenFile,
+				"-loglevel", level,
+			)
+			assert.NoError(t, err, stderr)
+
+			var credentials types.AWSTempCredentials
+			decoder := json.NewDecoder(bytes.NewReader([]byte(stdout)))
+			decoder.DisallowUnknownFields()
+			assert.NoError(t, decoder.Decode(&credentials), "stdout: %s", stdout)
+			assert.False(t, decoder.More(), "stdout contains more than the credentials: %s", stdout)
+			assert.Equal(t, "ASIAMOCKACCESSKEY", credentials.AccessKeyId)
+
+			if level == "DEBUG" {
+				assert.Contains(t, stderr, "Successfully retrieved AWS credentials")
+			}
+		})
+	}
+}
+
+// TestAzureProviderRequiresAudience verifies that the azure provider is rejected without an audience
+func TestAzureProviderRequiresAudience(t *testing.T) {
+	_, stderr, err := runMain(t, []string{types.EnvTokenAudience + "="},
+		"-rolearn", "arn:aws:iam::123456789012:role/my-trusted-role",
+		"-provider", types.ProviderAzure,
+	)
+	assert.Error(t, err)
+	assert.Contains(t, stderr, "requires -audience")
+}
+
+// TestFIPSRequiresSupportedRegion verifies that -fips is rejected for regions without an STS FIPS endpoint
+func TestFIPSRequiresSupportedRegion(t *testing.T) {
+	_, stderr, err := runMain(t, nil,
+		"-rolearn", "arn:aws:iam::123456789012:role/my-trusted-role",
+		"-stsregion", "eu-west-1",
+		"-fips",
+	)
+	assert.Error(t, err)
+	assert.Contains(t, stderr, "no FIPS endpoint in region eu-west-1")
+}
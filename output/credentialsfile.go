@@ -0,0 +1,210 @@
+package output
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"janus/filelock"
+	"janus/types"
+)
+
+// Keys written into a shared credentials file profile. x_security_token_expires is not read
+// by AWS SDKs but records when the written credentials become stale.
+const (
+	keyAccessKeyID     = "aws_access_key_id"
+	keySecretAccessKey = "aws_secret_access_key"
+	keySessionToken    = "aws_session_token"
+	keyTokenExpires    = "x_security_token_expires"
+)
+
+// lockExtension is appended to the shared credentials file path to name its lock file
+const lockExtension = ".lock"
+
+// CredentialsFilePath returns the shared credentials file path from AWS_SHARED_CREDENTIALS_FILE,
+// defaulting to ~/.aws/credentials
+func CredentialsFilePath() (string, error) {
+	if path := os.Getenv(types.EnvSharedCredentialsFile); path != "" {
+		return path, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("couldn't determine home directory: %w", err)
+	}
+	return filepath.Join(home, ".aws", "credentials"), nil
+}
+
+// WriteProfile writes credentials into the named profile of the shared credentials file.
+// Other profiles, comments and unrelated keys of the profile are preserved, and the file
+// is replaced atomically so readers never observe a partial write. A symbolic link to the
+// file is kept and its target updated, and concurrent writers are serialized by a lock file
+// next to it, waited for until ctx is done.
+func WriteProfile(ctx context.Context, path, profile string, credentials *types.AWSTempCredentials) (err error) {
+	path, err = resolveSymlinks(path)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create shared credentials directory: %w", err)
+	}
+
+	lock, err := filelock.Acquire(ctx, path+lockExtension)
+	if err != nil {
+		return fmt.Errorf("failed to lock shared credentials file: %w", err)
+	}
+	defer func() {
+		if unlockErr := lock.Unlock(); unlockErr != nil && err == nil {
+			err = fmt.Errorf("failed to unlock shared credentials file: %w", unlockErr)
+		}
+	}()
+
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to read shared credentials file: %w", err)
+	}
+
+	values := [][2]string{
+		{keyAccessKeyID, credentials.AccessKeyId},
+		{keySecretAccessKey, credentials.SecretAccessKey},
+		{keySessionToken, credentials.SessionToken},
+		{keyTokenExpires, credentials.Expiration.UTC().Format(time.RFC3339)},
+	}
+	content := updateProfile(string(data), profile, values)
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary credentials file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to set credentials file permissions: %w", err)
+	}
+	if _, err := tmp.WriteString(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write credentials file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync credentials file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close credentials file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace shared credentials file: %w", err)
+	}
+	return nil
+}
+
+// resolveSymlinks returns the file a symbolic link points to, so that replacing the file does
+// not replace the link. Paths which do not exist yet are returned unchanged.
+func resolveSymlinks(path string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return path, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve shared credentials file: %w", err)
+	}
+	return resolved, nil
+}
+
+// updateProfile sets the given keys in the profile section of INI formatted content,
+// appending the section when it does not exist yet
+func updateProfile(content, profile string, values [][2]string) string {
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	if content == "" {
+		lines = nil
+	}
+
+	start, end := -1, len(lines)
+	for i, line := range lines {
+		name, ok := sectionName(line)
+		if !ok {
+			continue
+		}
+		if start >= 0 {
+			end = i
+			break
+		}
+		if name == profile {
+			start = i
+		}
+	}
+
+	if start < 0 {
+		if len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) != "" {
+			lines = append(lines, "")
+		}
+		lines = append(lines, "["+profile+"]")
+		for _, kv := range values {
+			lines = append(lines, kv[0]+" = "+kv[1])
+		}
+		return strings.Join(lines, "\n") + "\n"
+	}
+
+	section := lines[start+1 : end]
+	written := make(map[string]bool, len(values))
+	lastKey := 0
+	for i, line := range section {
+		key, ok := keyName(line)
+		if !ok {
+			continue
+		}
+		lastKey = i + 1
+		for _, kv := range values {
+			if key == kv[0] {
+				section[i] = kv[0] + " = " + kv[1]
+				written[kv[0]] = true
+			}
+		}
+	}
+
+	var missing []string
+	for _, kv := range values {
+		if !written[kv[0]] {
+			missing = append(missing, kv[0]+" = "+kv[1])
+		}
+	}
+
+	updated := make([]string, 0, len(lines)+len(missing))
+	updated = append(updated, lines[:start+1]...)
+	updated = append(updated, section[:lastKey]...)
+	updated = append(updated, missing...)
+	updated = append(updated, section[lastKey:]...)
+	updated = append(updated, lines[end:]...)
+	return strings.Join(updated, "\n") + "\n"
+}
+
+// sectionName returns the profile name of an INI section header line
+func sectionName(line string) (string, bool) {
+	trimmed := strings.TrimSpace(line)
+	if !strings.HasPrefix(trimmed, "[") || !strings.HasSuffix(trimmed, "]") {
+		return "", false
+	}
+	return strings.TrimSpace(trimmed[1 : len(trimmed)-1]), true
+}
+
+// keyName returns the lower case key of an INI key/value line
+func keyName(line string) (string, bool) {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, ";") {
+		return "", false
+	}
+	key, _, ok := strings.Cut(trimmed, "=")
+	if !ok {
+		return "", false
+	}
+	return strings.ToLower(strings.TrimSpace(key)), true
+}
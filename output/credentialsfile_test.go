@@ -0,0 +1,192 @@
+package output
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"janus/filelock"
+	"janus/types"
+)
+
+func TestWriteProfileNewFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".aws", "credentials")
+
+	if err := WriteProfile(context.Background(), path, "janus", testCredentials); err != nil {
+		t.Fatalf("WriteProfile() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read credentials file: %v", err)
+	}
+	assert.Equal(t, "[janus]\n"+
+		"aws_access_key_id = AKIAEXAMPLE\n"+
+		"aws_secret_access_key = secret/key+value\n"+
+		"aws_session_token = session'token\n"+
+		"x_security_token_expires = 2026-10-16T12:00:00Z\n", string(data))
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat credentials file: %v", err)
+	}
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm(), "Credentials file should only be accessible by owner")
+}
+
+func TestWriteProfilePreservesOtherContent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials")
+	existing := "# managed by hand\n" +
+		"[default]\n" +
+		"aws_access_key_id = DEFAULTKEY\n" +
+		"aws_secret_access_key = defaultsecret\n" +
+		"\n" +
+		"[janus]\n" +
+		"; written by janus-go\n" +
+		"aws_access_key_id=OLDKEY\n" +
+		"aws_secret_access_key=oldsecret\n" +
+		"region = eu-west-1\n" +
+		"\n" +
+		"[other]\n" +
+		"aws_access_key_id = OTHERKEY\n"
+	if err := os.WriteFile(path, []byte(existing), 0o600); err != nil {
+		t.Fatalf("Failed to write credentials file: %v", err)
+	}
+
+	if err := WriteProfile(context.Background(), path, "janus", testCredentials); err != nil {
+		t.Fatalf("WriteProfile() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read credentials file: %v", err)
+	}
+	assert.Equal(t, "# managed by hand\n"+
+		"[default]\n"+
+		"aws_access_key_id = DEFAULTKEY\n"+
+		"aws_secret_access_key = defaultsecret\n"+
+		"\n"+
+		"[janus]\n"+
+		"; written by janus-go\n"+
+		"aws_access_key_id = AKIAEXAMPLE\n"+
+		"aws_secret_access_key = secret/key+value\n"+
+		"region = eu-west-1\n"+
+		"aws_session_token = session'token\n"+
+		"x_security_token_expires = 2026-10-16T12:00:00Z\n"+
+		"\n"+
+		"[other]\n"+
+		"aws_access_key_id = OTHERKEY\n", string(data))
+}
+
+func TestWriteProfileAppendsSection(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials")
+	if err := os.WriteFile(path, []byte("[default]\naws_access_key_id = DEFAULTKEY\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write credentials file: %v", err)
+	}
+
+	if err := WriteProfile(context.Background(), path, "janus", testCredentials); err != nil {
+		t.Fatalf("WriteProfile() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read credentials file: %v", err)
+	}
+	assert.Equal(t, "[default]\n"+
+		"aws_access_key_id = DEFAULTKEY\n"+
+		"\n"+
+		"[janus]\n"+
+		"aws_access_key_id = AKIAEXAMPLE\n"+
+		"aws_secret_access_key = secret/key+value\n"+
+		"aws_session_token = session'token\n"+
+		"x_security_token_expires = 2026-10-16T12:00:00Z\n", string(data))
+}
+
+func TestWriteProfileThroughSymlink(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "dotfiles", "credentials")
+	if err := os.MkdirAll(filepath.Dir(target), 0o700); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(target, []byte("[default]\naws_access_key_id = DEFAULTKEY\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write credentials file: %v", err)
+	}
+	link := filepath.Join(dir, "credentials")
+	if err := os.Symlink(filepath.Join("dotfiles", "credentials"), link); err != nil {
+		t.Skipf("Symbolic links not supported: %v", err)
+	}
+
+	if err := WriteProfile(context.Background(), link, "janus", testCredentials); err != nil {
+		t.Fatalf("WriteProfile() error = %v", err)
+	}
+
+	info, err := os.Lstat(link)
+	if err != nil {
+		t.Fatalf("Failed to stat credentials link: %v", err)
+	}
+	assert.NotZero(t, info.Mode()&os.ModeSymlink, "Symbolic link should not be replaced")
+
+	data, err := os.ReadFile(target)
+	if err != nil {
+		t.Fatalf("Failed to read credentials file: %v", err)
+	}
+	assert.Contains(t, string(data), "aws_access_key_id = DEFAULTKEY\n", "Link target should keep other profiles")
+	assert.Contains(t, string(data), "[janus]\naws_access_key_id = AKIAEXAMPLE\n", "Link target should be updated")
+}
+
+func TestWriteProfileConcurrentWriters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials")
+
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, WriteProfile(context.Background(), path, fmt.Sprintf("profile-%d", i), testCredentials))
+		}()
+	}
+	wg.Wait()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read credentials file: %v", err)
+	}
+	for i := range 10 {
+		assert.Contains(t, string(data), fmt.Sprintf("[profile-%d]\n", i), "No concurrent write should be lost")
+	}
+}
+
+func TestWriteProfileLockTimeout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials")
+	lock, err := filelock.Acquire(context.Background(), path+lockExtension)
+	if err != nil {
+		t.Fatalf("Failed to acquire lock: %v", err)
+	}
+	defer lock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	err = WriteProfile(ctx, path, "janus", testCredentials)
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "Waiting for another writer should stop at the deadline")
+	_, err = os.Stat(path)
+	assert.ErrorIs(t, err, os.ErrNotExist, "Credentials should not be written without the lock")
+}
+
+func TestCredentialsFilePath(t *testing.T) {
+	t.Setenv(types.EnvSharedCredentialsFile, "/tmp/janus-credentials")
+	path, err := CredentialsFilePath()
+	assert.NoError(t, err)
+	assert.Equal(t, "/tmp/janus-credentials", path)
+
+	t.Setenv(types.EnvSharedCredentialsFile, "")
+	t.Setenv("HOME", "/home/janus")
+	path, err = CredentialsFilePath()
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join("/home/janus", ".aws", "credentials"), path)
+}
@@ -0,0 +1,85 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"janus/types"
+)
+
+// Environment variables understood by AWS SDKs and CLIs
+const (
+	envAccessKeyID          = "AWS_ACCESS_KEY_ID"
+	envSecretAccessKey      = "AWS_SECRET_ACCESS_KEY"
+	envSessionToken         = "AWS_SESSION_TOKEN"
+	envCredentialExpiration = "AWS_CREDENTIAL_EXPIRATION"
+)
+
+// Write writes credentials to w in the given output format
+func Write(w io.Writer, format string, credentials *types.AWSTempCredentials) error {
+	if format == types.OutputJSON {
+		// AWS CLI config credential_process requires JSON output containing credentials
+		// and expiration time
+		return json.NewEncoder(w).Encode(credentials)
+	}
+
+	var line func(name, value string) string
+	switch format {
+	case types.OutputEnv:
+		line = func(name, value string) string {
+			return fmt.Sprintf("export %s=%s\n", name, posixQuote(value))
+		}
+	case types.OutputFish:
+		line = func(name, value string) string {
+			return fmt.Sprintf("set -gx %s %s;\n", name, fishQuote(value))
+		}
+	case types.OutputPowerShell:
+		line = func(name, value string) string {
+			return fmt.Sprintf("$env:%s = %s\n", name, powerShellQuote(value))
+		}
+	case types.OutputDotenv:
+		// Values are written unquoted since some consumers such as docker --env-file
+		// do not strip quotes
+		line = func(name, value string) string {
+			return fmt.Sprintf("%s=%s\n", name, value)
+		}
+	default:
+		return fmt.Errorf("unsupported output format: %s", format)
+	}
+
+	var b strings.Builder
+	for _, variable := range Environment(credentials) {
+		name, value, _ := strings.Cut(variable, "=")
+		b.WriteString(line(name, value))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Environment returns credentials as NAME=value environment variable assignments
+func Environment(credentials *types.AWSTempCredentials) []string {
+	return []string{
+		envAccessKeyID + "=" + credentials.AccessKeyId,
+		envSecretAccessKey + "=" + credentials.SecretAccessKey,
+		envSessionToken + "=" + credentials.SessionToken,
+		envCredentialExpiration + "=" + credentials.Expiration.UTC().Format(time.RFC3339),
+	}
+}
+
+// posixQuote quotes a value for POSIX shells using single quotes
+func posixQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// fishQuote quotes a value for fish, where backslash and single quote are escaped inside single quotes
+func fishQuote(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
+
+// powerShellQuote quotes a value for PowerShell, where single quotes are doubled inside single quotes
+func powerShellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
@@ -0,0 +1,84 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"janus/types"
+)
+
+var testCredentials = &types.AWSTempCredentials{
+	Version:         1,
+	AccessKeyId:     "AKIAEXAMPLE",
+	SecretAccessKey: "secret/key+value",
+	SessionToken:    "session'token",
+	Expiration:      time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC),
+}
+
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{
+			format: types.OutputEnv,
+			want: "export AWS_ACCESS_KEY_ID='AKIAEXAMPLE'\n" +
+				"export AWS_SECRET_ACCESS_KEY='secret/key+value'\n" +
+				"export AWS_SESSION_TOKEN='session'\\''token'\n" +
+				"export AWS_CREDENTIAL_EXPIRATION='2026-10-16T12:00:00Z'\n",
+		},
+		{
+			format: types.OutputFish,
+			want: "set -gx AWS_ACCESS_KEY_ID 'AKIAEXAMPLE';\n" +
+				"set -gx AWS_SECRET_ACCESS_KEY 'secret/key+value';\n" +
+				"set -gx AWS_SESSION_TOKEN 'session\\'token';\n" +
+				"set -gx AWS_CREDENTIAL_EXPIRATION '2026-10-16T12:00:00Z';\n",
+		},
+		{
+			format: types.OutputPowerShell,
+			want: "$env:AWS_ACCESS_KEY_ID = 'AKIAEXAMPLE'\n" +
+				"$env:AWS_SECRET_ACCESS_KEY = 'secret/key+value'\n" +
+				"$env:AWS_SESSION_TOKEN = 'session''token'\n" +
+				"$env:AWS_CREDENTIAL_EXPIRATION = '2026-10-16T12:00:00Z'\n",
+		},
+		{
+			format: types.OutputDotenv,
+			want: "AWS_ACCESS_KEY_ID=AKIAEXAMPLE\n" +
+				"AWS_SECRET_ACCESS_KEY=secret/key+value\n" +
+				"AWS_SESSION_TOKEN=session'token\n" +
+				"AWS_CREDENTIAL_EXPIRATION=2026-10-16T12:00:00Z\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Write(&buf, tt.format, testCredentials); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, types.OutputJSON, testCredentials); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	var parsed types.AWSTempCredentials
+	if err := json.Unmarshal(buf.Bytes(), &parsed); err != nil {
+		t.Fatalf("Output is not valid JSON: %v", err)
+	}
+	assert.Equal(t, *testCredentials, parsed)
+}
+
+func TestWriteUnsupportedFormat(t *testing.T) {
+	var buf bytes.Buffer
+	assert.Error(t, Write(&buf, "yaml", testCredentials))
+}
@@ -0,0 +1,106 @@
+package profile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	dirName  = "janus-go"
+	fileName = "config.yaml"
+)
+
+// File is a janus-go configuration file holding named profiles
+type File struct {
+	Profiles map[string]Profile `yaml:"profiles"`
+}
+
+// Profile holds the settings of a named profile. Empty fields leave the corresponding
+// flag defaults unchanged.
+type Profile struct {
+	RoleArn         string            `yaml:"role_arn"`
+	STSRegion       string            `yaml:"sts_region"`
+	STSEndpoint     string            `yaml:"sts_endpoint"`
+	FIPS            *bool             `yaml:"fips"`
+	DualStack       *bool             `yaml:"dualstack"`
+	SessionID       string            `yaml:"session_id"`
+	SessionTemplate string            `yaml:"session_template"`
+	Audience        string            `yaml:"audience"`
+	Provider        string            `yaml:"provider"`
+	TokenFile       string            `yaml:"token_file"`
+	Impersonate     string            `yaml:"impersonate"`
+	Delegates       []string          `yaml:"delegates"`
+	Duration        time.Duration     `yaml:"duration"`
+	Chain           []RoleHop         `yaml:"chain"`
+	Policy          string            `yaml:"policy"`
+	PolicyARNs      []string          `yaml:"policy_arns"`
+	SourceIdentity  string            `yaml:"source_identity"`
+	Tags            map[string]string `yaml:"tags"`
+	MetadataTags    map[string]string `yaml:"metadata_tags"`
+	TransitiveTags  []string          `yaml:"transitive_tags"`
+	Output          string            `yaml:"output"`
+	Cache           *bool             `yaml:"cache"`
+	CacheDir        string            `yaml:"cache_dir"`
+	CacheRefresh    time.Duration     `yaml:"cache_refresh"`
+	Retries         *int              `yaml:"retries"`
+	RetryDelay      time.Duration     `yaml:"retry_delay"`
+	Timeout         time.Duration     `yaml:"timeout"`
+}
+
+// RoleHop holds the settings of a chained role
+type RoleHop struct {
+	RoleArn     string        `yaml:"role_arn"`
+	SessionName string        `yaml:"session_name"`
+	ExternalID  string        `yaml:"external_id"`
+	Duration    time.Duration `yaml:"duration"`
+}
+
+// DefaultPath returns the default configuration file path located under the user configuration directory
+func DefaultPath() (string, error) {
+	userConfigDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("couldn't determine user configuration directory: %w", err)
+	}
+	return filepath.Join(userConfigDir, dirName, fileName), nil
+}
+
+// Load reads and parses a configuration file. Unknown settings are rejected so that
+// typos don't silently fall back to defaults.
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read configuration file: %w", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	var file File
+	if err := decoder.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse configuration file %s: %w", path, err)
+	}
+	return &file, nil
+}
+
+// Profile returns the named profile
+func (f *File) Profile(name string) (Profile, error) {
+	p, ok := f.Profiles[name]
+	if !ok {
+		names := make([]string, 0, len(f.Profiles))
+		for n := range f.Profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return Profile{}, fmt.Errorf("profile %q not found in configuration file (available profiles: %s)", name, strings.Join(names, ", "))
+	}
+	return p, nil
+}
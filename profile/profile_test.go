@@ -0,0 +1,104 @@
+package profile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write configuration file: %v", err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	path := writeConfig(t, `
+profiles:
+  production:
+    role_arn: arn:aws:iam::123456789012:role/landing
+    sts_region: eu-west-1
+    sts_endpoint: https://vpce-0123-abcd.sts.eu-west-1.vpce.amazonaws.com
+    dualstack: true
+    audience: https://landing.example.com
+    duration: 2h
+    output: env
+    cache: true
+    policy_arns:
+      - arn:aws:iam::aws:policy/ReadOnlyAccess
+    chain:
+      - role_arn: arn:aws:iam::210987654321:role/workload
+        external_id: my-external-id
+        duration: 30m
+    tags:
+      team: platform
+    metadata_tags:
+      project: project-id
+    transitive_tags:
+      - team
+  staging:
+    role_arn: arn:aws:iam::123456789012:role/staging
+`)
+
+	file, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	p, err := file.Profile("production")
+	if err != nil {
+		t.Fatalf("Profile() error = %v", err)
+	}
+	assert.Equal(t, "arn:aws:iam::123456789012:role/landing", p.RoleArn)
+	assert.Equal(t, "eu-west-1", p.STSRegion)
+	assert.Equal(t, "https://vpce-0123-abcd.sts.eu-west-1.vpce.amazonaws.com", p.STSEndpoint)
+	assert.Nil(t, p.FIPS)
+	if assert.NotNil(t, p.DualStack) {
+		assert.True(t, *p.DualStack)
+	}
+	assert.Equal(t, "https://landing.example.com", p.Audience)
+	assert.Equal(t, 2*time.Hour, p.Duration)
+	assert.Equal(t, "env", p.Output)
+	if assert.NotNil(t, p.Cache) {
+		assert.True(t, *p.Cache)
+	}
+	assert.Equal(t, []string{"arn:aws:iam::aws:policy/ReadOnlyAccess"}, p.PolicyARNs)
+	assert.Equal(t, []RoleHop{{
+		RoleArn:    "arn:aws:iam::210987654321:role/workload",
+		ExternalID: "my-external-id",
+		Duration:   30 * time.Minute,
+	}}, p.Chain)
+	assert.Equal(t, map[string]string{"team": "platform"}, p.Tags)
+	assert.Equal(t, map[string]string{"project": "project-id"}, p.MetadataTags)
+	assert.Equal(t, []string{"team"}, p.TransitiveTags)
+
+	p, err = file.Profile("staging")
+	assert.NoError(t, err)
+	assert.Nil(t, p.Cache, "Unset cache setting should be nil")
+
+	_, err = file.Profile("missing")
+	assert.ErrorContains(t, err, "production, staging", "Error should list available profiles")
+}
+
+func TestLoadInvalid(t *testing.T) {
+	_, err := Load(writeConfig(t, "profiles:\n  test:\n    rolearn: arn:aws:iam::123456789012:role/typo\n"))
+	assert.Error(t, err, "Unknown settings should be rejected")
+
+	_, err = Load(writeConfig(t, "profiles:\n  test:\n    duration: forever\n"))
+	assert.Error(t, err, "Invalid duration should be rejected")
+
+	_, err = Load(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err, "Missing file should be rejected")
+}
+
+func TestLoadEmpty(t *testing.T) {
+	file, err := Load(writeConfig(t, ""))
+	assert.NoError(t, err)
+	_, err = file.Profile("default")
+	assert.Error(t, err)
+}
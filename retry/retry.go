@@ -0,0 +1,120 @@
+// Package retry retries operations failing with transient errors using exponential backoff and jitter
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"cloud.google.com/go/compute/metadata"
+	"golang.org/x/oauth2"
+
+	"janus/logger"
+	"janus/types"
+)
+
+// Policy configures how often and how long failed operations are retried
+type Policy struct {
+	// Retries is the number of attempts made after the first one failed, zero disables retries
+	Retries int
+	// BaseDelay is the backoff before the first retry, doubled for every further retry
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff between attempts
+	MaxDelay time.Duration
+}
+
+// NewPolicy returns a retry policy with the default maximum delay
+func NewPolicy(retries int, baseDelay time.Duration) Policy {
+	return Policy{
+		Retries:   retries,
+		BaseDelay: baseDelay,
+		MaxDelay:  types.RetryMaxDelay,
+	}
+}
+
+// Delay returns the delay before the given retry, counted from 1. The delay is drawn at random
+// up to the exponential backoff ("full jitter") so that many clients failing at the same time,
+// such as pods starting on a new node, do not retry in lockstep.
+func (p Policy) Delay(retry int) time.Duration {
+	backoff := p.MaxDelay
+	if retry < 1 {
+		retry = 1
+	}
+	if shift := retry - 1; shift < 32 && p.BaseDelay<<shift > 0 && p.BaseDelay<<shift < p.MaxDelay {
+		backoff = p.BaseDelay << shift
+	}
+	if backoff <= 0 {
+		return 0
+	}
+	return rand.N(backoff + 1)
+}
+
+// Do calls fn until it succeeds, fails with an error for which retryable returns false, the
+// retries of the policy are exhausted or ctx is done. The last error of fn is returned.
+func Do(ctx context.Context, policy Policy, retryable func(error) bool, fn func() error) error {
+	for retry := 1; ; retry++ {
+		err := fn()
+		if err == nil || retry > policy.Retries || ctx.Err() != nil || !retryable(err) {
+			return err
+		}
+
+		delay := policy.Delay(retry)
+		logger.Logger.Debug("Retrying after transient error", "retry", retry, "delay", delay, "error", err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// StatusError reports an HTTP response with an unexpected status code
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+// Error returns the status code and response body
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("status %d: %s", e.StatusCode, e.Body)
+}
+
+// IsTransient reports whether an operation failing with err is likely to succeed when retried:
+// throttling and server errors, timeouts, and failed or interrupted connections
+func IsTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return transientStatus(statusErr.StatusCode)
+	}
+	var retrieveErr *oauth2.RetrieveError
+	if errors.As(err, &retrieveErr) && retrieveErr.Response != nil {
+		return transientStatus(retrieveErr.Response.StatusCode)
+	}
+	var metadataErr *metadata.Error
+	if errors.As(err, &metadataErr) {
+		return transientStatus(metadataErr.Code)
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// transientStatus reports whether an HTTP status code indicates throttling or a server error
+func transientStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
@@ -0,0 +1,114 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/compute/metadata"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+
+	"janus/logger"
+)
+
+func init() {
+	// Initialize logger for tests
+	logger.InitLogger("ERROR")
+}
+
+func TestPolicyDelay(t *testing.T) {
+	policy := Policy{Retries: 10, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	for range 100 {
+		assert.LessOrEqual(t, policy.Delay(1), 100*time.Millisecond)
+		assert.LessOrEqual(t, policy.Delay(3), 400*time.Millisecond)
+		assert.LessOrEqual(t, policy.Delay(10), time.Second)
+		assert.LessOrEqual(t, policy.Delay(100), time.Second)
+		assert.GreaterOrEqual(t, policy.Delay(100), time.Duration(0))
+	}
+
+	assert.Equal(t, time.Duration(0), Policy{}.Delay(1))
+}
+
+func TestDo(t *testing.T) {
+	transient := &StatusError{StatusCode: http.StatusServiceUnavailable}
+	policy := Policy{Retries: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+
+	t.Run("succeeds after transient errors", func(t *testing.T) {
+		calls := 0
+		err := Do(context.Background(), policy, IsTransient, func() error {
+			calls++
+			if calls < 3 {
+				return transient
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("gives up after the retries", func(t *testing.T) {
+		calls := 0
+		err := Do(context.Background(), policy, IsTransient, func() error {
+			calls++
+			return transient
+		})
+		assert.ErrorIs(t, err, transient)
+		assert.Equal(t, 4, calls)
+	})
+
+	t.Run("does not retry permanent errors", func(t *testing.T) {
+		calls := 0
+		err := Do(context.Background(), policy, IsTransient, func() error {
+			calls++
+			return &StatusError{StatusCode: http.StatusForbidden}
+		})
+		assert.Error(t, err)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("stops when the context is done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		calls := 0
+		err := Do(ctx, Policy{Retries: 10, BaseDelay: time.Hour, MaxDelay: time.Hour}, IsTransient, func() error {
+			calls++
+			cancel()
+			return transient
+		})
+		assert.ErrorIs(t, err, transient)
+		assert.Equal(t, 1, calls)
+	})
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"throttled", &StatusError{StatusCode: http.StatusTooManyRequests}, true},
+		{"server error", fmt.Errorf("token request failed with %w", &StatusError{StatusCode: http.StatusBadGateway}), true},
+		{"forbidden", &StatusError{StatusCode: http.StatusForbidden}, false},
+		{"oauth2 server error", &oauth2.RetrieveError{Response: &http.Response{StatusCode: http.StatusServiceUnavailable}}, true},
+		{"oauth2 invalid grant", &oauth2.RetrieveError{Response: &http.Response{StatusCode: http.StatusBadRequest}}, false},
+		{"metadata server error", &metadata.Error{Code: http.StatusServiceUnavailable}, true},
+		{"metadata not defined", metadata.NotDefinedError("instance/hostname"), false},
+		{"connection refused", &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, true},
+		{"connection reset", fmt.Errorf("read: %w", syscall.ECONNRESET), true},
+		{"canceled", context.Canceled, false},
+		{"other", errors.New("unsupported credential type"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsTransient(tt.err))
+		})
+	}
+}
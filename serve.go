@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"path"
+	"syscall"
+
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+
+	"janus/exitcode"
+	"janus/logger"
+	"janus/server"
+	"janus/types"
+)
+
+// runServe serves credentials over the ECS/EKS container credentials protocol so that tools
+// without credential_process support can use AWS_CONTAINER_CREDENTIALS_FULL_URI
+func runServe(args []string) {
+	fs, opts := newFlagSet(os.Args[0] + " " + commandServe)
+	listenAddress := fs.String("listen", types.ServeListenDefault, "Loopback address the container credentials endpoint listens on")
+	config := parseConfig(fs, opts, args)
+
+	if err := server.ValidateLoopbackAddress(*listenAddress); err != nil {
+		exitWithUsage(fs, err)
+	}
+	if _, err := server.AuthorizationToken(); err != nil {
+		exitWithError(exitcode.Wrap(exitcode.ClassUsage, err))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	refresher := startRefresher(ctx, config)
+
+	if err := server.ListenAndServe(ctx, *listenAddress, server.NewECSHandler(refresher, server.AuthorizationToken)); err != nil {
+		exitWithError(err)
+	}
+}
+
+// runIMDS emulates the EC2 instance metadata service (IMDSv2) for workloads which can only
+// obtain credentials from an instance role, using AWS_EC2_METADATA_SERVICE_ENDPOINT
+func runIMDS(args []string) {
+	fs, opts := newFlagSet(os.Args[0] + " " + commandIMDS)
+	listenAddress := fs.String("listen", types.IMDSListenDefault, "Address the instance metadata endpoint listens on")
+	config := parseConfig(fs, opts, args)
+
+	if _, _, err := net.SplitHostPort(*listenAddress); err != nil {
+		exitWithUsage(fs, fmt.Errorf("invalid listen address %s: %w", *listenAddress, err))
+	}
+	if err := server.ValidateLoopbackAddress(*listenAddress); err != nil {
+		logger.Logger.Warn("Instance metadata endpoint is reachable from other hosts", "address", *listenAddress)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	refresher := startRefresher(ctx, config)
+
+	if err := server.ListenAndServe(ctx, *listenAddress, server.NewIMDSHandler(refresher, path.Base(finalRoleArn(config)))); err != nil {
+		exitWithError(err)
+	}
+}
+
+// startRefresher fetches initial credentials and keeps refreshing them in the background.
+// It exits the program when the initial credentials cannot be retrieved, so that failures
+// surface on startup rather than on the first client request.
+func startRefresher(ctx context.Context, config types.Config) *server.Refresher {
+	config, sessionIdentifier := resolveSession(ctx, config)
+
+	refresher := server.NewRefresher(refreshFetch(ctx, config, sessionIdentifier), config.CacheRefreshWindow)
+
+	if _, err := refresher.Credentials(ctx); err != nil {
+		exitWithError(err)
+	}
+	go refresher.Run(ctx)
+
+	return refresher
+}
+
+// refreshFetch returns the function fetching credentials on every refresh. The identity token
+// source is created on first use and kept for the life of ctx rather than of a single refresh,
+// so that identity tokens are reused until they expire. The refresher never calls it concurrently.
+func refreshFetch(ctx context.Context, config types.Config, sessionIdentifier string) server.FetchFunc {
+	var tokenRetriever stscreds.IdentityTokenRetriever
+	return func(fetchCtx context.Context) (*types.AWSTempCredentials, error) {
+		return fetchCredentialsWith(fetchCtx, config, sessionIdentifier, func(context.Context) (stscreds.IdentityTokenRetriever, error) {
+			if tokenRetriever == nil {
+				retriever, err := identityTokenRetriever(ctx, config)
+				if err != nil {
+					return nil, err
+				}
+				tokenRetriever = retriever
+			}
+			return tokenRetriever, nil
+		})
+	}
+}
+
+// finalRoleArn returns the ARN of the role whose credentials are issued, which is the
+// last chained role when role chaining is used
+func finalRoleArn(config types.Config) string {
+	if len(config.Chain) > 0 {
+		return config.Chain[len(config.Chain)-1].RoleArn
+	}
+	return config.RoleArn
+}
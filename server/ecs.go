@@ -0,0 +1,101 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"janus/logger"
+	"janus/types"
+)
+
+// ecsCredentials is the response format of the ECS container credentials endpoint
+type ecsCredentials struct {
+	AccessKeyId     string    `json:"AccessKeyId"`
+	SecretAccessKey string    `json:"SecretAccessKey"`
+	Token           string    `json:"Token"`
+	Expiration      time.Time `json:"Expiration"`
+}
+
+// ecsError is the error response format understood by AWS SDK container credential providers
+type ecsError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// AuthorizationToken returns the Authorization header value clients must present, read from the
+// file named by AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE or from AWS_CONTAINER_AUTHORIZATION_TOKEN.
+// The file takes precedence, matching the behaviour of AWS SDKs.
+func AuthorizationToken() (string, error) {
+	if path := os.Getenv(types.EnvContainerAuthorizationTokenFile); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read authorization token file: %w", err)
+		}
+		token := strings.TrimSpace(string(data))
+		if token == "" {
+			return "", fmt.Errorf("authorization token file %s is empty", path)
+		}
+		return token, nil
+	}
+
+	if token := os.Getenv(types.EnvContainerAuthorizationToken); token != "" {
+		return token, nil
+	}
+
+	return "", fmt.Errorf("authorization token required, set %s or %s", types.EnvContainerAuthorizationToken, types.EnvContainerAuthorizationTokenFile)
+}
+
+// NewECSHandler returns a handler implementing the ECS/EKS container credentials protocol.
+// Requests must carry an Authorization header matching the token returned by authorizationToken,
+// which is called on every request so that rotated token files are picked up.
+func NewECSHandler(refresher *Refresher, authorizationToken func() (string, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeECSError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", "only GET requests are supported")
+			return
+		}
+
+		expected, err := authorizationToken()
+		if err != nil {
+			logger.Logger.Error("Failed to read authorization token", "error", err)
+			writeECSError(w, http.StatusInternalServerError, "InternalError", "authorization token unavailable")
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(expected)) != 1 {
+			logger.Logger.Warn("Rejected container credentials request with invalid authorization", "remoteAddr", r.RemoteAddr)
+			writeECSError(w, http.StatusUnauthorized, "Unauthorized", "invalid authorization token")
+			return
+		}
+
+		credentials, err := refresher.Credentials(r.Context())
+		if err != nil {
+			logger.Logger.Error("Failed to retrieve AWS credentials", "error", err)
+			writeECSError(w, http.StatusInternalServerError, "CredentialsUnavailable", "failed to retrieve AWS credentials")
+			return
+		}
+
+		writeJSON(w, http.StatusOK, ecsCredentials{
+			AccessKeyId:     credentials.AccessKeyId,
+			SecretAccessKey: credentials.SecretAccessKey,
+			Token:           credentials.SessionToken,
+			Expiration:      credentials.Expiration.UTC(),
+		})
+	})
+}
+
+func writeECSError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, ecsError{Code: code, Message: message})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		logger.Logger.Error("Failed to write response", "error", err)
+	}
+}
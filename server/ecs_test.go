@@ -0,0 +1,155 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/credentials/endpointcreds"
+	"github.com/stretchr/testify/assert"
+
+	"janus/logger"
+	"janus/types"
+)
+
+func init() {
+	// Initialize logger for tests
+	logger.InitLogger("ERROR")
+}
+
+func staticToken(token string) func() (string, error) {
+	return func() (string, error) {
+		return token, nil
+	}
+}
+
+func testCredentials(expiration time.Time) *types.AWSTempCredentials {
+	return &types.AWSTempCredentials{
+		Version:         1,
+		AccessKeyId:     "access_key",
+		SecretAccessKey: "secret_key",
+		SessionToken:    "session_token",
+		Expiration:      expiration,
+	}
+}
+
+// TestECSHandlerWithSDKProvider verifies the endpoint against the AWS SDK container credentials provider
+func TestECSHandlerWithSDKProvider(t *testing.T) {
+	expiration := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	refresher := NewRefresher(func(context.Context) (*types.AWSTempCredentials, error) {
+		return testCredentials(expiration), nil
+	}, 15*time.Minute)
+
+	srv := httptest.NewServer(NewECSHandler(refresher, staticToken("secret-auth-token")))
+	defer srv.Close()
+
+	provider := endpointcreds.New(srv.URL, func(o *endpointcreds.Options) {
+		o.AuthorizationToken = "secret-auth-token"
+	})
+	credentials, err := provider.Retrieve(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to retrieve credentials from endpoint: %v", err)
+	}
+
+	assert.Equal(t, "access_key", credentials.AccessKeyID)
+	assert.Equal(t, "secret_key", credentials.SecretAccessKey)
+	assert.Equal(t, "session_token", credentials.SessionToken)
+	assert.True(t, expiration.Equal(credentials.Expires), "Expiration mismatch")
+}
+
+func TestECSHandlerRejectsInvalidAuthorization(t *testing.T) {
+	refresher := NewRefresher(func(context.Context) (*types.AWSTempCredentials, error) {
+		t.Error("Credentials fetched for unauthorized request")
+		return nil, errors.New("unexpected fetch")
+	}, 0)
+	handler := NewECSHandler(refresher, staticToken("secret-auth-token"))
+
+	for _, header := range []string{"", "wrong-token"} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code, "Authorization %q should be rejected", header)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set("Authorization", "secret-auth-token")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code, "POST should be rejected")
+}
+
+func TestAuthorizationToken(t *testing.T) {
+	t.Setenv(types.EnvContainerAuthorizationToken, "")
+	t.Setenv(types.EnvContainerAuthorizationTokenFile, "")
+	_, err := AuthorizationToken()
+	assert.Error(t, err, "Missing token should fail")
+
+	t.Setenv(types.EnvContainerAuthorizationToken, "env-token")
+	token, err := AuthorizationToken()
+	assert.NoError(t, err)
+	assert.Equal(t, "env-token", token)
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("file-token\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write token file: %v", err)
+	}
+	t.Setenv(types.EnvContainerAuthorizationTokenFile, tokenFile)
+	token, err = AuthorizationToken()
+	assert.NoError(t, err)
+	assert.Equal(t, "file-token", token, "Token file should take precedence")
+}
+
+func TestRefresher(t *testing.T) {
+	fetches := 0
+	var fetchErr error
+	expiration := time.Now().Add(time.Hour)
+	refresher := NewRefresher(func(context.Context) (*types.AWSTempCredentials, error) {
+		fetches++
+		if fetchErr != nil {
+			return nil, fetchErr
+		}
+		return testCredentials(expiration), nil
+	}, 15*time.Minute)
+
+	ctx := context.Background()
+	_, err := refresher.Credentials(ctx)
+	assert.NoError(t, err)
+	_, err = refresher.Credentials(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, fetches, "Fresh credentials should be reused")
+
+	// Force a refresh which fails while current credentials are still valid
+	refresher.refreshAt = time.Now()
+	fetchErr = errors.New("sts unavailable")
+	credentials, err := refresher.Credentials(ctx)
+	assert.NoError(t, err, "Valid credentials should be served when refresh fails")
+	assert.NotNil(t, credentials)
+	assert.Equal(t, 2, fetches)
+}
+
+func TestRefreshTime(t *testing.T) {
+	now := time.Now()
+
+	refreshAt := refreshTime(now, now.Add(time.Hour), 15*time.Minute)
+	assert.True(t, refreshAt.Equal(now.Add(45*time.Minute)), "Refresh should happen the window before expiry")
+
+	refreshAt = refreshTime(now, now.Add(15*time.Minute), 15*time.Minute)
+	assert.True(t, refreshAt.Equal(now.Add(7*time.Minute+30*time.Second)), "Short-lived credentials should refresh halfway")
+}
+
+func TestValidateLoopbackAddress(t *testing.T) {
+	for _, address := range []string{"127.0.0.1:9911", "localhost:9911", "[::1]:9911"} {
+		assert.NoError(t, ValidateLoopbackAddress(address), "Address %s should be accepted", address)
+	}
+	for _, address := range []string{"0.0.0.0:9911", "10.0.0.1:9911", ":9911", "127.0.0.1"} {
+		assert.Error(t, ValidateLoopbackAddress(address), "Address %s should be rejected", address)
+	}
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"janus/logger"
+)
+
+const (
+	imdsTokenHeader       = "X-Aws-Ec2-Metadata-Token"
+	imdsTokenTTLHeader    = "X-Aws-Ec2-Metadata-Token-Ttl-Seconds"
+	imdsTokenPath         = "/latest/api/token"
+	imdsCredentialsPath   = "/latest/meta-data/iam/security-credentials/"
+	imdsTokenTTLMax       = 21600 // Longest session token lifetime accepted by IMDSv2, in seconds
+	imdsCredentialsType   = "AWS-HMAC"
+	imdsCredentialsStatus = "Success"
+)
+
+// imdsCredentials is the response format of the EC2 instance metadata security credentials endpoint
+type imdsCredentials struct {
+	Code            string    `json:"Code"`
+	LastUpdated     time.Time `json:"LastUpdated"`
+	Type            string    `json:"Type"`
+	AccessKeyId     string    `json:"AccessKeyId"`
+	SecretAccessKey string    `json:"SecretAccessKey"`
+	Token           string    `json:"Token"`
+	Expiration      time.Time `json:"Expiration"`
+}
+
+// imdsHandler emulates the IMDSv2 session token handshake and the instance role credentials endpoints
+type imdsHandler struct {
+	refresher *Refresher
+	roleName  string
+
+	mu     sync.Mutex
+	tokens map[string]time.Time
+}
+
+// NewIMDSHandler returns a handler emulating the EC2 instance metadata service (IMDSv2 only)
+// which serves credentials for the given role name
+func NewIMDSHandler(refresher *Refresher, roleName string) http.Handler {
+	return &imdsHandler{
+		refresher: refresher,
+		roleName:  roleName,
+		tokens:    make(map[string]time.Time),
+	}
+}
+
+func (h *imdsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == imdsTokenPath {
+		h.serveToken(w, r)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.validToken(r.Header.Get(imdsTokenHeader)) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.URL.Path {
+	case imdsCredentialsPath, strings.TrimSuffix(imdsCredentialsPath, "/"):
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprint(w, h.roleName)
+	case imdsCredentialsPath + h.roleName:
+		h.serveCredentials(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// serveToken issues an IMDSv2 session token. Like EC2, requests which passed through
+// a proxy are refused so the token cannot be obtained via request forgery.
+func (h *imdsHandler) serveToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if r.Header.Get("X-Forwarded-For") != "" {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	ttl, err := strconv.Atoi(r.Header.Get(imdsTokenTTLHeader))
+	if err != nil || ttl < 1 || ttl > imdsTokenTTLMax {
+		http.Error(w, "invalid token TTL", http.StatusBadRequest)
+		return
+	}
+
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		logger.Logger.Error("Failed to generate metadata session token", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	token := hex.EncodeToString(tokenBytes)
+
+	now := time.Now()
+	h.mu.Lock()
+	for t, expires := range h.tokens {
+		if now.After(expires) {
+			delete(h.tokens, t)
+		}
+	}
+	h.tokens[token] = now.Add(time.Duration(ttl) * time.Second)
+	h.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.Header().Set(imdsTokenTTLHeader, strconv.Itoa(ttl))
+	fmt.Fprint(w, token)
+}
+
+func (h *imdsHandler) serveCredentials(w http.ResponseWriter, r *http.Request) {
+	credentials, err := h.refresher.Credentials(r.Context())
+	if err != nil {
+		logger.Logger.Error("Failed to retrieve AWS credentials", "error", err)
+		http.Error(w, "credentials unavailable", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, imdsCredentials{
+		Code:            imdsCredentialsStatus,
+		LastUpdated:     time.Now().UTC().Truncate(time.Second),
+		Type:            imdsCredentialsType,
+		AccessKeyId:     credentials.AccessKeyId,
+		SecretAccessKey: credentials.SecretAccessKey,
+		Token:           credentials.SessionToken,
+		Expiration:      credentials.Expiration.UTC(),
+	})
+}
+
+func (h *imdsHandler) validToken(token string) bool {
+	if token == "" {
+		return false
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	expires, ok := h.tokens[token]
+	return ok && time.Now().Before(expires)
+}
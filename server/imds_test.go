@@ -0,0 +1,115 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/ec2rolecreds"
+	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
+	"github.com/stretchr/testify/assert"
+
+	"janus/types"
+)
+
+func newTestIMDSServer(t *testing.T, expiration time.Time) *httptest.Server {
+	refresher := NewRefresher(func(context.Context) (*types.AWSTempCredentials, error) {
+		return testCredentials(expiration), nil
+	}, 15*time.Minute)
+
+	srv := httptest.NewServer(NewIMDSHandler(refresher, "my-trusted-role"))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// TestIMDSHandlerWithSDKClient verifies the emulated endpoints against the AWS SDK IMDS client
+func TestIMDSHandlerWithSDKClient(t *testing.T) {
+	expiration := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	srv := newTestIMDSServer(t, expiration)
+
+	client := imds.New(imds.Options{
+		Endpoint:       srv.URL,
+		EnableFallback: aws.FalseTernary,
+	})
+
+	ctx := context.Background()
+	output, err := client.GetMetadata(ctx, &imds.GetMetadataInput{Path: "iam/security-credentials/"})
+	if err != nil {
+		t.Fatalf("Failed to list instance roles: %v", err)
+	}
+	defer output.Content.Close()
+	roles, err := io.ReadAll(output.Content)
+	assert.NoError(t, err)
+	assert.Equal(t, "my-trusted-role", string(roles))
+
+	provider := ec2rolecreds.New(func(o *ec2rolecreds.Options) {
+		o.Client = client
+	})
+	credentials, err := provider.Retrieve(ctx)
+	if err != nil {
+		t.Fatalf("Failed to retrieve instance role credentials: %v", err)
+	}
+
+	assert.Equal(t, "access_key", credentials.AccessKeyID)
+	assert.Equal(t, "secret_key", credentials.SecretAccessKey)
+	assert.Equal(t, "session_token", credentials.SessionToken)
+	assert.True(t, expiration.Equal(credentials.Expires), "Expiration mismatch")
+}
+
+func TestIMDSHandlerRequiresToken(t *testing.T) {
+	srv := newTestIMDSServer(t, time.Now().Add(time.Hour))
+
+	resp, err := http.Get(srv.URL + imdsCredentialsPath + "my-trusted-role")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, "IMDSv1 requests should be rejected")
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+imdsCredentialsPath+"my-trusted-role", nil)
+	req.Header.Set(imdsTokenHeader, "invalid-token")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, "Unknown tokens should be rejected")
+}
+
+func TestIMDSHandlerTokenRequest(t *testing.T) {
+	srv := newTestIMDSServer(t, time.Now().Add(time.Hour))
+
+	tests := []struct {
+		name       string
+		ttl        string
+		forwarded  bool
+		wantStatus int
+	}{
+		{name: "valid TTL", ttl: "60", wantStatus: http.StatusOK},
+		{name: "missing TTL", ttl: "", wantStatus: http.StatusBadRequest},
+		{name: "TTL too long", ttl: "21601", wantStatus: http.StatusBadRequest},
+		{name: "forwarded request", ttl: "60", forwarded: true, wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodPut, srv.URL+imdsTokenPath, nil)
+			if tt.ttl != "" {
+				req.Header.Set(imdsTokenTTLHeader, tt.ttl)
+			}
+			if tt.forwarded {
+				req.Header.Set("X-Forwarded-For", "10.0.0.1")
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+			resp.Body.Close()
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+		})
+	}
+}
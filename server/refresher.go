@@ -0,0 +1,98 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"janus/logger"
+	"janus/types"
+)
+
+const (
+	refreshRetryInterval = 10 * time.Second // Delay before retrying a failed background refresh
+)
+
+// FetchFunc retrieves a new set of temporary AWS credentials
+type FetchFunc func(ctx context.Context) (*types.AWSTempCredentials, error)
+
+// Refresher keeps temporary AWS credentials in memory and refreshes them ahead of expiry
+type Refresher struct {
+	fetch  FetchFunc
+	window time.Duration
+
+	mu          sync.Mutex
+	credentials *types.AWSTempCredentials
+	refreshAt   time.Time
+}
+
+// NewRefresher creates a refresher which renews credentials the given window before they expire
+func NewRefresher(fetch FetchFunc, window time.Duration) *Refresher {
+	return &Refresher{
+		fetch:  fetch,
+		window: window,
+	}
+}
+
+// Credentials returns the current credentials, fetching new ones when they are due for refresh.
+// When a refresh fails, credentials which have not yet expired keep being served.
+func (r *Refresher) Credentials(ctx context.Context) (*types.AWSTempCredentials, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if r.credentials != nil && now.Before(r.refreshAt) {
+		return r.credentials, nil
+	}
+
+	credentials, err := r.fetch(ctx)
+	if err != nil {
+		if r.credentials != nil && now.Before(r.credentials.Expiration) {
+			logger.Logger.Warn("Failed to refresh AWS credentials, serving current credentials", "error", err, "expiration", r.credentials.Expiration)
+			return r.credentials, nil
+		}
+		return nil, err
+	}
+
+	r.credentials = credentials
+	r.refreshAt = refreshTime(now, credentials.Expiration, r.window)
+	logger.Logger.Debug("Refreshed AWS credentials", "expiration", credentials.Expiration, "refreshAt", r.refreshAt)
+	return credentials, nil
+}
+
+// Run refreshes credentials in the background until the context is cancelled, so that
+// requests are served without waiting for STS
+func (r *Refresher) Run(ctx context.Context) {
+	for {
+		wait := refreshRetryInterval
+		if _, err := r.Credentials(ctx); err != nil {
+			logger.Logger.Error("Failed to refresh AWS credentials", "error", err)
+		} else {
+			r.mu.Lock()
+			if untilRefresh := time.Until(r.refreshAt); untilRefresh > wait {
+				wait = untilRefresh
+			}
+			r.mu.Unlock()
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// refreshTime returns when credentials should be refreshed. Credentials are refreshed the window
+// before expiration, but no earlier than halfway through their lifetime so that short-lived
+// credentials are not refreshed on every request.
+func refreshTime(fetchedAt, expiration time.Time, window time.Duration) time.Time {
+	refreshAt := expiration.Add(-window)
+	halfway := fetchedAt.Add(expiration.Sub(fetchedAt) / 2)
+	if refreshAt.Before(halfway) {
+		return halfway
+	}
+	return refreshAt
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"janus/logger"
+)
+
+const (
+	readHeaderTimeout = 5 * time.Second  // Maximum time to read request headers
+	shutdownTimeout   = 10 * time.Second // Maximum time to wait for in-flight requests on shutdown
+)
+
+// ListenAndServe serves the handler on the given address until the context is cancelled
+func ListenAndServe(ctx context.Context, address string, handler http.Handler) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", address, err)
+	}
+
+	srv := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.Serve(listener)
+	}()
+	logger.Logger.Info("Serving AWS credentials", "address", listener.Addr().String())
+
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("server failed: %w", err)
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to shut down server: %w", err)
+	}
+	if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("server failed: %w", err)
+	}
+	return nil
+}
+
+// ValidateLoopbackAddress validates that the listen address binds to a loopback interface.
+// AWS SDKs only accept plain HTTP container credential endpoints on loopback addresses.
+func ValidateLoopbackAddress(address string) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("invalid listen address %s: %w", address, err)
+	}
+	if host == "localhost" {
+		return nil
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !ip.IsLoopback() {
+		return fmt.Errorf("invalid listen address %s: must be a loopback address such as 127.0.0.1", address)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"janus/exitcode"
+	"janus/gcp"
+	"janus/kubernetes"
+	"janus/logger"
+	"janus/retry"
+	"janus/types"
+)
+
+// resolveSession looks up the settings which depend on where janus-go runs: session tags, the
+// source identity and the session identifier. It exits the program on failure.
+func resolveSession(ctx context.Context, config types.Config) (types.Config, string) {
+	config.Tags = getSessionTags(ctx, config)
+	config.SourceIdentity = getSourceIdentity(ctx, config)
+	return config, getSessionIdentifier(ctx, config)
+}
+
+// newMetadataClient creates a GCP metadata client retrying with the configured policy
+func newMetadataClient(ctx context.Context, config types.Config) *gcp.MetadataClient {
+	client := gcp.NewMetadataClient(ctx)
+	client.Retry = retry.NewPolicy(config.Retries, config.RetryDelay)
+	return client
+}
+
+// getSourceIdentity renders the source identity template, exiting the program on failure
+func getSourceIdentity(ctx context.Context, config types.Config) string {
+	if config.SourceIdentity == "" {
+		return ""
+	}
+
+	ctx, cancel := withTimeout(ctx, config)
+	defer cancel()
+
+	sourceIdentity, err := gcp.RenderTemplate("source identity", config.SourceIdentity, gcp.NewTemplateData(ctx, config, newMetadataClient(ctx, config)))
+	if err != nil {
+		exitWithError(err)
+	}
+	if err := types.ValidateSourceIdentity(sourceIdentity); err != nil {
+		exitWithError(exitcode.Wrap(exitcode.ClassUsage, err))
+	}
+	logger.Logger.Debug("Rendered source identity", "sourceIdentity", sourceIdentity)
+	return sourceIdentity
+}
+
+// getSessionTags returns the static session tags followed by the metadata tags with their
+// looked up values, exiting the program on failure
+func getSessionTags(ctx context.Context, config types.Config) []types.SessionTag {
+	if len(config.MetadataTags) == 0 {
+		return config.Tags
+	}
+
+	ctx, cancel := withTimeout(ctx, config)
+	defer cancel()
+
+	var gcpMetadataClient *gcp.MetadataClient
+	tags := append([]types.SessionTag(nil), config.Tags...)
+	for _, metadataTag := range config.MetadataTags {
+		if gcpMetadataClient == nil && isGCPTagSource(metadataTag.Source) {
+			gcpMetadataClient = newMetadataClient(ctx, config)
+		}
+
+		value, err := metadataTagValue(ctx, gcpMetadataClient, metadataTag.Source)
+		if err != nil {
+			exitWithError(fmt.Errorf("failed to look up %s for session tag %q: %w", metadataTag.Source, metadataTag.Key, err))
+		}
+		logger.Logger.Debug("Resolved session tag", "key", metadataTag.Key, "source", metadataTag.Source, "value", value)
+		tags = append(tags, types.SessionTag{Key: metadataTag.Key, Value: value})
+	}
+
+	// Looked up values are only known now, so the tags are validated once more
+	if err := types.ValidateSessionTags(tags, nil, config.TransitiveTagKeys); err != nil {
+		exitWithError(err)
+	}
+	return tags
+}
+
+// isGCPTagSource reports whether the value of a tag source comes from the GCP metadata server
+func isGCPTagSource(source string) bool {
+	switch source {
+	case types.TagSourceProjectID, types.TagSourceZone, types.TagSourceClusterName, types.TagSourceInstanceName:
+		return true
+	}
+	return false
+}
+
+// metadataTagValue looks up the value of a tag source
+func metadataTagValue(ctx context.Context, client *gcp.MetadataClient, source string) (string, error) {
+	switch source {
+	case types.TagSourceProjectID:
+		return client.ProjectIDWithContext(ctx)
+	case types.TagSourceZone:
+		return client.ZoneWithContext(ctx)
+	case types.TagSourceClusterName:
+		return client.ClusterNameWithContext(ctx)
+	case types.TagSourceInstanceName:
+		return client.InstanceNameWithContext(ctx)
+	case types.TagSourcePod:
+		return kubernetes.PodName()
+	case types.TagSourceNamespace:
+		return kubernetes.Namespace()
+	case types.TagSourceServiceAccount:
+		return kubernetes.ServiceAccount()
+	}
+	return "", fmt.Errorf("unknown session tag source: %s", source)
+}
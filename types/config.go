@@ -1,9 +1,71 @@
 package types
 
+import "time"
+
 // Config holds the configuration settings
 type Config struct {
 	// PrintIdToken indicates whether to print the identity token when log level is DEBUG
 	PrintIdToken bool
 	// LogLevel specifies the logging level (DEBUG, INFO, WARN, ERROR)
 	LogLevel string
+	// RoleArn is the AWS IAM role ARN to assume
+	RoleArn string
+	// STSRegion is the AWS STS region to which requests are made
+	STSRegion string
+	// STSEndpoint is a custom STS endpoint URL, such as a VPC interface endpoint, used instead of the regional endpoint
+	STSEndpoint string
+	// UseFIPS resolves the FIPS endpoint of the STS region
+	UseFIPS bool
+	// UseDualStack resolves the dual-stack (IPv4 and IPv6) endpoint of the STS region
+	UseDualStack bool
+	// SessionID is the AWS session identifier, derived from environment or GCP metadata when empty
+	SessionID string
+	// SessionNameTemplate renders the session identifier from gcp.TemplateData fields when SessionID is empty
+	SessionNameTemplate string
+	// Audience is the audience requested for identity tokens, defaults to IDENTITY_TOKEN_AUDIENCE or the provider's default
+	Audience string
+	// Provider is the identity token provider, detected from the environment when empty or "auto"
+	Provider string
+	// TokenFile is a file containing the identity token, re-read on every exchange, used instead of Google credentials
+	TokenFile string
+	// Impersonate is the service account whose identity token is used, minted via the IAM Credentials API
+	Impersonate string
+	// Delegates is the chain of service accounts through which Impersonate is impersonated
+	Delegates []string
+	// Duration is the requested role session duration, zero uses the STS default
+	Duration time.Duration
+	// Chain lists roles assumed in order after the web identity role
+	Chain []RoleHop
+	// Policy is an inline JSON session policy further restricting the role permissions
+	Policy string
+	// PolicyARNs are managed session policies further restricting the role permissions
+	PolicyARNs []string
+	// SourceIdentity is the source identity set on the first chained role, a template over
+	// gcp.TemplateData fields until it is rendered
+	SourceIdentity string
+	// Tags are session tags set on the first chained role, static ones from the configuration
+	// followed by resolved MetadataTags
+	Tags []SessionTag
+	// MetadataTags are session tags whose values are looked up from GCP metadata or Kubernetes
+	MetadataTags []MetadataTag
+	// TransitiveTagKeys are keys of Tags which persist through subsequent role chaining
+	TransitiveTagKeys []string
+	// OutputFormat selects how credentials are printed (json, env, fish, powershell, dotenv)
+	OutputFormat string
+	// WriteProfile is the shared credentials file profile credentials are written to instead of stdout
+	WriteProfile string
+	// CredentialsFile is the shared credentials file path, defaults to AWS_SHARED_CREDENTIALS_FILE or ~/.aws/credentials
+	CredentialsFile string
+	// Cache enables the on-disk credential cache
+	Cache bool
+	// CacheDir is the directory where cached credentials are stored
+	CacheDir string
+	// CacheRefreshWindow is how long before expiration cached credentials are refreshed
+	CacheRefreshWindow time.Duration
+	// Retries is the number of retries after transient metadata, identity token and STS failures
+	Retries int
+	// RetryDelay is the backoff before the first retry, doubled for every further retry
+	RetryDelay time.Duration
+	// Timeout is the deadline for fetching credentials including retries, zero means no deadline
+	Timeout time.Duration
 }
@@ -0,0 +1,65 @@
+package types
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// JWTClaims holds the claims of an identity token which AWS trust policies match on
+type JWTClaims struct {
+	Issuer   string      `json:"iss"`
+	Audience JWTAudience `json:"aud"`
+	Subject  string      `json:"sub"`
+	Email    string      `json:"email,omitempty"`
+	Expiry   float64     `json:"exp"`
+}
+
+// JWTAudience is the aud claim, which may be a single string or a list of strings
+type JWTAudience []string
+
+// UnmarshalJSON accepts both forms of the aud claim
+func (a *JWTAudience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = JWTAudience{single}
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("invalid aud claim: %w", err)
+	}
+	*a = list
+	return nil
+}
+
+// ExpiresAt returns the time of the exp claim, or the zero time when the claim is missing
+func (c JWTClaims) ExpiresAt() time.Time {
+	if c.Expiry == 0 {
+		return time.Time{}
+	}
+	return time.Unix(int64(c.Expiry), 0)
+}
+
+// ParseJWTClaims decodes the claims of a JWT. The signature is not verified, the claims are
+// only inspected, for example to know when a new token has to be fetched.
+func ParseJWTClaims(token string) (*JWTClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("identity token is not a JWT")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode identity token claims: %w", err)
+	}
+
+	var claims JWTClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("failed to parse identity token claims: %w", err)
+	}
+	return &claims, nil
+}
@@ -0,0 +1,48 @@
+package types
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+)
+
+// testJWT builds an unsigned JWT with the given claims payload
+func testJWT(payload string) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	return header + "." + base64.RawURLEncoding.EncodeToString([]byte(payload)) + ".signature"
+}
+
+func TestParseJWTClaims(t *testing.T) {
+	claims, err := ParseJWTClaims(testJWT(`{"iss":"https://accounts.google.com","aud":"gcp","sub":"1234","email":"sa@my-project.iam.gserviceaccount.com","exp":1767225600}`))
+	if err != nil {
+		t.Fatalf("ParseJWTClaims() unexpected error = %v", err)
+	}
+	if claims.Issuer != "https://accounts.google.com" || claims.Subject != "1234" || claims.Email != "sa@my-project.iam.gserviceaccount.com" {
+		t.Errorf("ParseJWTClaims() = %+v", claims)
+	}
+	if len(claims.Audience) != 1 || claims.Audience[0] != "gcp" {
+		t.Errorf("ParseJWTClaims() audience = %v, want [gcp]", claims.Audience)
+	}
+	if !claims.ExpiresAt().Equal(time.Unix(1767225600, 0)) {
+		t.Errorf("ExpiresAt() = %v", claims.ExpiresAt())
+	}
+
+	claims, err = ParseJWTClaims(testJWT(`{"aud":["sts.amazonaws.com","gcp"]}`))
+	if err != nil {
+		t.Fatalf("ParseJWTClaims() unexpected error = %v", err)
+	}
+	if len(claims.Audience) != 2 || !claims.ExpiresAt().IsZero() {
+		t.Errorf("ParseJWTClaims() = %+v", claims)
+	}
+
+	for name, token := range map[string]string{
+		"opaque token":   "mock_token",
+		"invalid base64": "header.!!!.signature",
+		"invalid claims": testJWT(`not json`),
+		"invalid aud":    testJWT(`{"aud":42}`),
+	} {
+		if _, err := ParseJWTClaims(token); err == nil {
+			t.Errorf("ParseJWTClaims(%s) expected error", name)
+		}
+	}
+}
@@ -5,9 +5,92 @@ import (
 )
 
 const (
-	GCPTokenAudience = "gcp"
-	STSRegionDefault = "us-east-1"
-	EnvSessionID     = "AWS_SESSION_IDENTIFIER" // Environment variable name for session identifier
+	GCPTokenAudience    = "gcp"
+	GitHubTokenAudience = "sts.amazonaws.com" // Default audience of GitHub Actions identity tokens, as used by AWS
+	STSRegionDefault    = "us-east-1"
+	EnvSessionID        = "AWS_SESSION_IDENTIFIER"  // Environment variable name for session identifier
+	EnvTokenAudience    = "IDENTITY_TOKEN_AUDIENCE" // Environment variable name for identity token audience
+	EnvConfigFile       = "JANUS_CONFIG"            // Environment variable name for configuration file path
+	EnvTokenFile        = "JANUS_TOKEN_FILE"        // Environment variable name for identity token file path
+
+	EnvContainerAuthorizationToken     = "AWS_CONTAINER_AUTHORIZATION_TOKEN"      // Authorization header value expected by the container credentials endpoint
+	EnvContainerAuthorizationTokenFile = "AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE" // File containing the authorization header value
+	EnvSharedCredentialsFile           = "AWS_SHARED_CREDENTIALS_FILE"            // Path of the AWS shared credentials file
+	EnvRegion                          = "AWS_REGION"                             // AWS region used by SDKs
+	EnvDefaultRegion                   = "AWS_DEFAULT_REGION"                     // AWS region used by the AWS CLI
+	EnvSecurityToken                   = "AWS_SECURITY_TOKEN"                     // Legacy name of the session token variable
+	ServeListenDefault                 = "127.0.0.1:9911"                         // Default container credentials endpoint address
+	IMDSListenDefault                  = "127.0.0.1:9912"                         // Default instance metadata endpoint address
+
+	CacheRefreshWindowDefault = 15 * time.Minute // Cached credentials closer to expiry than this are refreshed
+
+	STSDurationMin     = 15 * time.Minute // Shortest role session duration accepted by STS
+	STSDurationMax     = 12 * time.Hour   // Longest role session duration accepted by STS
+	STSDurationDefault = time.Hour        // Session duration of web identity roles when none is requested
+
+	SessionPolicyMaxLength = 2048 // Plaintext limit shared by inline and managed session policies
+	SessionPolicyARNsMax   = 10   // Maximum number of managed session policy ARNs
+
+	ChainedDurationMax = time.Hour // Longest session duration STS allows for role chaining
+
+	SessionNameMinLength = 2  // Shortest role session name accepted by STS
+	SessionNameMaxLength = 64 // Longest role session name accepted by STS
+
+	SessionNameTemplateKubernetes = "{{.Namespace}}-{{.ServiceAccount}}-{{.Pod}}" // Default session name of Kubernetes pods
+
+	SessionTagsMax        = 50  // Maximum number of session tags per STS request
+	SessionTagKeyMaxLen   = 128 // Longest session tag key in characters
+	SessionTagValueMaxLen = 256 // Longest session tag value in characters
+
+	TagSourceProjectID      = "project-id"      // GCP project ID from the metadata server
+	TagSourceZone           = "zone"            // GCE zone from the metadata server
+	TagSourceClusterName    = "cluster-name"    // GKE cluster name from the metadata server
+	TagSourceInstanceName   = "instance-name"   // GCE instance name from the metadata server
+	TagSourcePod            = "pod"             // Kubernetes pod name
+	TagSourceNamespace      = "namespace"       // Kubernetes namespace of the pod
+	TagSourceServiceAccount = "service-account" // Kubernetes service account of the pod
+
+	RetriesDefault    = 3                      // Retries after transient metadata, token and STS failures
+	RetriesMax        = 10                     // Largest accepted number of retries
+	RetryDelayDefault = 200 * time.Millisecond // Backoff before the first retry, doubled for every further retry
+	RetryMaxDelay     = 5 * time.Second        // Longest backoff between two attempts
+
+	OutputJSON       = "json"       // credential_process JSON output
+	OutputEnv        = "env"        // POSIX shell export statements
+	OutputFish       = "fish"       // fish shell set statements
+	OutputPowerShell = "powershell" // PowerShell environment assignments
+	OutputDotenv     = "dotenv"     // KEY=value lines for .env files
+
+	LogFormatJSON   = "json"   // One JSON object per log record
+	LogFormatText   = "text"   // key=value log records
+	LogOutputStderr = "stderr" // Log to standard error
+	LogOutputSyslog = "syslog" // Log to the local syslog daemon
+
+	ErrorFormatText = "text" // Errors are logged
+	ErrorFormatJSON = "json" // Errors are written to stderr as a JSON object with exit code, class and hint
+
+	ProviderAuto   = "auto"   // Detect the identity provider from the environment
+	ProviderGCP    = "gcp"    // Google credentials or metadata server
+	ProviderFile   = "file"   // Identity token read from a file
+	ProviderGitHub = "github" // GitHub Actions OIDC token
+	ProviderGitLab = "gitlab" // GitLab CI id_tokens
+	ProviderAzure  = "azure"  // Azure managed identity
+
+	EnvGitHubTokenRequestURL   = "ACTIONS_ID_TOKEN_REQUEST_URL"   // GitHub Actions OIDC token endpoint
+	EnvGitHubTokenRequestToken = "ACTIONS_ID_TOKEN_REQUEST_TOKEN" // Bearer token for the GitHub Actions OIDC token endpoint
+	EnvGitLabCI                = "GITLAB_CI"                      // Set to "true" inside GitLab CI jobs
+	EnvGitLabTokenVariable     = "JANUS_GITLAB_TOKEN_VARIABLE"    // Name of the GitLab id_tokens variable holding the identity token
+	EnvGitLabIDToken           = "GITLAB_OIDC_TOKEN"              // Default GitLab id_tokens variable name
+	EnvGitLabJobJWT            = "CI_JOB_JWT_V2"                  // Deprecated GitLab CI job JWT
+	EnvAzureIdentityEndpoint   = "IDENTITY_ENDPOINT"              // Azure App Service and Container Apps managed identity endpoint
+	EnvAzureIdentityHeader     = "IDENTITY_HEADER"                // Secret header value for the Azure managed identity endpoint
+	EnvAzureClientID           = "AZURE_CLIENT_ID"                // Client ID of a user-assigned Azure managed identity
+
+	EnvKubernetesServiceHost = "KUBERNETES_SERVICE_HOST" // Kubernetes API server address set in every pod
+	EnvPodName               = "POD_NAME"                // Kubernetes pod name exposed through the downward API
+	EnvPodNamespace          = "POD_NAMESPACE"           // Kubernetes namespace exposed through the downward API
+	EnvPodServiceAccount     = "POD_SERVICE_ACCOUNT"     // Kubernetes service account name exposed through the downward API
+	EnvNodeName              = "NODE_NAME"               // Kubernetes node name exposed through the downward API
 )
 
 // AWSTempCredentials represents temporary AWS credentials
@@ -18,3 +101,23 @@ type AWSTempCredentials struct {
 	SessionToken    string    `json:"SessionToken"`
 	Expiration      time.Time `json:"Expiration"`
 }
+
+// RoleHop describes a role assumed with sts:AssumeRole using credentials of the previous role
+type RoleHop struct {
+	RoleArn     string
+	SessionName string
+	ExternalID  string
+	Duration    time.Duration
+}
+
+// SessionTag is a session tag passed to STS when assuming a role
+type SessionTag struct {
+	Key   string
+	Value string
+}
+
+// MetadataTag is a session tag whose value is looked up from a tag source, see TagSource* constants
+type MetadataTag struct {
+	Key    string
+	Source string
+}
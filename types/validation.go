@@ -1,15 +1,38 @@
 package types
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/url"
 	"regexp"
 	"strings"
+	"time"
+	"unicode/utf8"
 )
 
 // AWS IAM role ARN pattern: arn:aws:iam::123456789012:role/RoleName
 // Also supports AWS partitions (aws, aws-cn, aws-us-gov)
 var arnPattern = regexp.MustCompile(`^arn:(aws|aws-cn|aws-us-gov):iam::\d{12}:role\/[a-zA-Z0-9+=,.@\-_/]+$`)
 
+// AWS IAM managed policy ARN pattern: arn:aws:iam::123456789012:policy/PolicyName
+// AWS managed policies use "aws" in place of the account ID
+var policyArnPattern = regexp.MustCompile(`^arn:(aws|aws-cn|aws-us-gov):iam::(\d{12}|aws):policy\/[a-zA-Z0-9+=,.@\-_/]+$`)
+
+// STS RoleSessionName allows 2-64 characters: upper and lower case alphanumeric plus =,.@-
+var sessionNamePattern = regexp.MustCompile(`^[\w+=,.@-]{2,64}$`)
+
+// STS ExternalId allows 2-1224 characters: upper and lower case alphanumeric plus =,.@:/-
+var externalIDPattern = regexp.MustCompile(`^[\w+=,.@:/-]+$`)
+
+// STS SourceIdentity allows 2-64 characters: upper and lower case alphanumeric plus =,.@-
+var sourceIdentityPattern = regexp.MustCompile(`^[\w+=,.@-]{2,64}$`)
+
+// Google service account email: any local part followed by a domain, e.g. name@project.iam.gserviceaccount.com
+var serviceAccountEmailPattern = regexp.MustCompile(`^[^@\s/]+@[a-z0-9.-]+\.[a-z]+$`)
+
+// STS session tag keys and values allow letters, digits, spaces and _.:/=+-@
+var sessionTagPattern = regexp.MustCompile(`^[\p{L}\p{Z}\p{N}_.:/=+\-@]*$`)
+
 // Matches standard AWS region format: {area}-{sub}-{number}
 // Covers commercial, GovCloud (us-gov-*), and China (cn-*) regions.
 var regionPattern = regexp.MustCompile(`^(us(-gov)?|af|ap|ca|eu|me|sa|cn|il)-(central|north|south|east|west|northeast|northwest|southeast|southwest)-\d$`)
@@ -41,3 +64,318 @@ func ValidateSTSRegion(region string) error {
 
 	return nil
 }
+
+// ValidateDuration validates that the provided role session duration is within the range
+// accepted by STS. A zero duration is valid and means the STS default is used.
+func ValidateDuration(duration time.Duration) error {
+	if duration == 0 {
+		return nil
+	}
+
+	if duration < STSDurationMin || duration > STSDurationMax {
+		return fmt.Errorf("invalid session duration: %s (must be between %s and %s)", duration, STSDurationMin, STSDurationMax)
+	}
+
+	if duration%time.Second != 0 {
+		return fmt.Errorf("invalid session duration: %s (must be a whole number of seconds)", duration)
+	}
+
+	return nil
+}
+
+// ValidatePolicyArn validates that the provided string is a valid AWS IAM managed policy ARN
+func ValidatePolicyArn(arn string) error {
+	if arn == "" {
+		return fmt.Errorf("policy ARN cannot be empty")
+	}
+
+	if !policyArnPattern.MatchString(arn) {
+		return fmt.Errorf("invalid AWS policy ARN format: %s (expected format: arn:aws:iam::123456789012:policy/PolicyName)", arn)
+	}
+
+	return nil
+}
+
+// ValidateRetries validates the number of retries and the initial retry backoff
+func ValidateRetries(retries int, delay time.Duration) error {
+	if retries < 0 || retries > RetriesMax {
+		return fmt.Errorf("invalid retries: %d (must be between 0 and %d)", retries, RetriesMax)
+	}
+	if delay < 0 {
+		return fmt.Errorf("invalid retry delay: %s (must not be negative)", delay)
+	}
+
+	return nil
+}
+
+// ValidateSessionPolicies validates inline and managed session policies before they are sent
+// to STS. The inline policy must be a JSON object and, together with the managed policy ARNs,
+// must fit within the STS plaintext limit.
+func ValidateSessionPolicies(policy string, policyArns []string) error {
+	size := 0
+
+	if policy != "" {
+		var document map[string]any
+		if err := json.Unmarshal([]byte(policy), &document); err != nil {
+			return fmt.Errorf("invalid session policy: must be a JSON object: %w", err)
+		}
+		size += len(policy)
+	}
+
+	if len(policyArns) > SessionPolicyARNsMax {
+		return fmt.Errorf("too many session policy ARNs: %d (maximum is %d)", len(policyArns), SessionPolicyARNsMax)
+	}
+	for _, arn := range policyArns {
+		if err := ValidatePolicyArn(arn); err != nil {
+			return err
+		}
+		size += len(arn)
+	}
+
+	if size > SessionPolicyMaxLength {
+		return fmt.Errorf("session policies too large: %d characters (maximum is %d)", size, SessionPolicyMaxLength)
+	}
+
+	return nil
+}
+
+// ValidateSessionName validates that the provided string is a valid STS role session name
+func ValidateSessionName(name string) error {
+	if !sessionNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid role session name: %q (must be 2-64 characters of letters, digits and +=,.@_-)", name)
+	}
+
+	return nil
+}
+
+// ValidateRoleHop validates a chained role hop. Role chaining limits the session duration to one hour.
+func ValidateRoleHop(hop RoleHop) error {
+	if err := ValidateRoleArn(hop.RoleArn); err != nil {
+		return err
+	}
+
+	if hop.SessionName != "" {
+		if err := ValidateSessionName(hop.SessionName); err != nil {
+			return err
+		}
+	}
+
+	if hop.ExternalID != "" && (len(hop.ExternalID) < 2 || len(hop.ExternalID) > 1224 || !externalIDPattern.MatchString(hop.ExternalID)) {
+		return fmt.Errorf("invalid external ID for role %s (must be 2-1224 characters of letters, digits and +=,.@:/_-)", hop.RoleArn)
+	}
+
+	if hop.Duration != 0 {
+		if err := ValidateDuration(hop.Duration); err != nil {
+			return err
+		}
+		if hop.Duration > ChainedDurationMax {
+			return fmt.Errorf("invalid session duration for chained role %s: %s (role chaining allows at most %s)", hop.RoleArn, hop.Duration, ChainedDurationMax)
+		}
+	}
+
+	return nil
+}
+
+// ValidateOutputFormat validates that the provided string is a supported credentials output format
+func ValidateOutputFormat(format string) error {
+	switch format {
+	case OutputJSON, OutputEnv, OutputFish, OutputPowerShell, OutputDotenv:
+		return nil
+	}
+
+	return fmt.Errorf("invalid output format: %s (expected one of %s, %s, %s, %s, %s)", format, OutputJSON, OutputEnv, OutputFish, OutputPowerShell, OutputDotenv)
+}
+
+// ValidateErrorFormat validates that the provided string is a supported error output format
+func ValidateErrorFormat(format string) error {
+	switch format {
+	case ErrorFormatText, ErrorFormatJSON:
+		return nil
+	}
+
+	return fmt.Errorf("invalid error format: %s (expected one of %s, %s)", format, ErrorFormatText, ErrorFormatJSON)
+}
+
+// ValidateProvider validates that the provided string is a supported identity provider
+func ValidateProvider(provider string) error {
+	switch provider {
+	case ProviderAuto, ProviderGCP, ProviderFile, ProviderGitHub, ProviderGitLab, ProviderAzure:
+		return nil
+	}
+
+	return fmt.Errorf("invalid identity provider: %s (expected one of %s, %s, %s, %s, %s, %s)", provider, ProviderAuto, ProviderGCP, ProviderFile, ProviderGitHub, ProviderGitLab, ProviderAzure)
+}
+
+// ValidateProfileName validates that the provided string can be used as a shared credentials file profile name
+func ValidateProfileName(name string) error {
+	if name == "" {
+		return fmt.Errorf("profile name cannot be empty")
+	}
+
+	if strings.ContainsAny(name, "[]\r\n") || strings.TrimSpace(name) != name {
+		return fmt.Errorf("invalid profile name: %q (must not contain brackets, line breaks or surrounding whitespace)", name)
+	}
+
+	return nil
+}
+
+// ValidateServiceAccountEmail validates that the provided string looks like a Google service account email
+func ValidateServiceAccountEmail(email string) error {
+	if !serviceAccountEmailPattern.MatchString(email) {
+		return fmt.Errorf("invalid service account email: %q (expected format: name@project.iam.gserviceaccount.com)", email)
+	}
+
+	return nil
+}
+
+// ValidateSessionTags validates session tags before they are sent to STS. Keys must be unique
+// ignoring case, metadata tags must name a known source, and transitive keys must refer to
+// one of the tags.
+func ValidateSessionTags(tags []SessionTag, metadataTags []MetadataTag, transitiveKeys []string) error {
+	if count := len(tags) + len(metadataTags); count > SessionTagsMax {
+		return fmt.Errorf("too many session tags: %d (maximum is %d)", count, SessionTagsMax)
+	}
+
+	keys := make(map[string]bool, len(tags)+len(metadataTags))
+	addKey := func(key string) error {
+		if err := validateSessionTagKey(key); err != nil {
+			return err
+		}
+		if keys[strings.ToLower(key)] {
+			return fmt.Errorf("duplicate session tag key: %q (keys are case-insensitive)", key)
+		}
+		keys[strings.ToLower(key)] = true
+		return nil
+	}
+
+	for _, tag := range tags {
+		if err := addKey(tag.Key); err != nil {
+			return err
+		}
+		if utf8.RuneCountInString(tag.Value) > SessionTagValueMaxLen || !sessionTagPattern.MatchString(tag.Value) {
+			return fmt.Errorf("invalid value for session tag %q: %q (must be at most %d characters of letters, digits, spaces and _.:/=+-@)", tag.Key, tag.Value, SessionTagValueMaxLen)
+		}
+	}
+
+	for _, tag := range metadataTags {
+		if err := addKey(tag.Key); err != nil {
+			return err
+		}
+		switch tag.Source {
+		case TagSourceProjectID, TagSourceZone, TagSourceClusterName, TagSourceInstanceName, TagSourcePod, TagSourceNamespace, TagSourceServiceAccount:
+		default:
+			return fmt.Errorf("invalid source for session tag %q: %s (expected one of %s, %s, %s, %s, %s, %s, %s)", tag.Key, tag.Source,
+				TagSourceProjectID, TagSourceZone, TagSourceClusterName, TagSourceInstanceName, TagSourcePod, TagSourceNamespace, TagSourceServiceAccount)
+		}
+	}
+
+	for _, key := range transitiveKeys {
+		if !keys[strings.ToLower(key)] {
+			return fmt.Errorf("transitive tag key %q does not match any session tag", key)
+		}
+	}
+
+	return nil
+}
+
+// validateSessionTagKey validates a session tag key. Keys starting with aws: are reserved.
+func validateSessionTagKey(key string) error {
+	length := utf8.RuneCountInString(key)
+	if length == 0 || length > SessionTagKeyMaxLen || !sessionTagPattern.MatchString(key) {
+		return fmt.Errorf("invalid session tag key: %q (must be 1-%d characters of letters, digits, spaces and _.:/=+-@)", key, SessionTagKeyMaxLen)
+	}
+	if strings.HasPrefix(strings.ToLower(key), "aws:") {
+		return fmt.Errorf("invalid session tag key: %q (the aws: prefix is reserved)", key)
+	}
+	return nil
+}
+
+// ValidateSourceIdentity validates that the provided string is a valid STS source identity
+func ValidateSourceIdentity(sourceIdentity string) error {
+	if !sourceIdentityPattern.MatchString(sourceIdentity) {
+		return fmt.Errorf("invalid source identity: %q (must be 2-64 characters of letters, digits and +=,.@_-)", sourceIdentity)
+	}
+
+	return nil
+}
+
+// ValidateSTSEndpoint validates that the provided string is an http or https URL of an STS endpoint
+func ValidateSTSEndpoint(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+		return fmt.Errorf("invalid STS endpoint: %s (expected an http or https URL such as https://sts.eu-west-1.amazonaws.com)", endpoint)
+	}
+
+	return nil
+}
+
+// stsFIPSRegions lists the regions in which STS has a FIPS endpoint
+var stsFIPSRegions = map[string]bool{
+	"us-east-1":     true,
+	"us-east-2":     true,
+	"us-west-1":     true,
+	"us-west-2":     true,
+	"us-gov-east-1": true,
+	"us-gov-west-1": true,
+}
+
+// ValidateSTSFIPSRegion validates that STS has a FIPS endpoint in the provided region, since
+// endpoint resolution otherwise yields a host that doesn't exist
+func ValidateSTSFIPSRegion(region string) error {
+	if !stsFIPSRegions[strings.ToLower(strings.TrimSpace(region))] {
+		return fmt.Errorf("STS has no FIPS endpoint in region %s (supported: us-east-1, us-east-2, us-west-1, us-west-2, us-gov-east-1, us-gov-west-1)", region)
+	}
+
+	return nil
+}
+
+// ValidateSTSPartition validates that STS requests for the role are made in the partition of
+// its ARN, since STS only issues credentials for roles of its own partition. The partition is
+// taken from the endpoint host when it is an AWS domain, otherwise from the region.
+func ValidateSTSPartition(roleArn, region, endpoint string) error {
+	rolePartition, _, _ := strings.Cut(strings.TrimPrefix(roleArn, "arn:"), ":")
+
+	if endpoint != "" {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return fmt.Errorf("invalid STS endpoint: %s", endpoint)
+		}
+		// Private DNS names of VPC endpoints and local stand-ins can't be attributed to a partition
+		if partition := endpointPartition(u.Hostname()); partition != "" && partition != rolePartition {
+			return fmt.Errorf("STS endpoint %s is in the %s partition but role %s is in the %s partition", endpoint, partition, roleArn, rolePartition)
+		}
+		return nil
+	}
+
+	if partition := regionPartition(region); partition != rolePartition {
+		return fmt.Errorf("STS region %s is in the %s partition but role %s is in the %s partition", region, partition, roleArn, rolePartition)
+	}
+	return nil
+}
+
+// endpointPartition returns the AWS partition of an endpoint host, or an empty string for hosts outside of AWS domains
+func endpointPartition(host string) string {
+	host = strings.ToLower(host)
+	switch {
+	case strings.HasSuffix(host, ".amazonaws.com.cn"), strings.HasSuffix(host, ".api.amazonwebservices.com.cn"):
+		return "aws-cn"
+	case strings.HasSuffix(host, ".amazonaws.com"), strings.HasSuffix(host, ".api.aws"):
+		if strings.Contains(host, "us-gov-") {
+			return "aws-us-gov"
+		}
+		return "aws"
+	}
+	return ""
+}
+
+// regionPartition returns the AWS partition of a region
+func regionPartition(region string) string {
+	region = strings.ToLower(strings.TrimSpace(region))
+	switch {
+	case strings.HasPrefix(region, "cn-"):
+		return "aws-cn"
+	case strings.HasPrefix(region, "us-gov-"):
+		return "aws-us-gov"
+	}
+	return "aws"
+}
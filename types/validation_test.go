@@ -1,7 +1,10 @@
 package types
 
 import (
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestValidateRoleArn(t *testing.T) {
@@ -239,3 +242,490 @@ func TestValidateSTSRegion(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateDuration(t *testing.T) {
+	tests := []struct {
+		name     string
+		duration time.Duration
+		wantErr  bool
+	}{
+		{
+			name:     "zero uses STS default",
+			duration: 0,
+			wantErr:  false,
+		},
+		{
+			name:     "minimum duration",
+			duration: 900 * time.Second,
+			wantErr:  false,
+		},
+		{
+			name:     "one hour",
+			duration: time.Hour,
+			wantErr:  false,
+		},
+		{
+			name:     "maximum duration",
+			duration: 43200 * time.Second,
+			wantErr:  false,
+		},
+		{
+			name:     "below minimum",
+			duration: 899 * time.Second,
+			wantErr:  true,
+		},
+		{
+			name:     "above maximum",
+			duration: 43201 * time.Second,
+			wantErr:  true,
+		},
+		{
+			name:     "negative duration",
+			duration: -time.Hour,
+			wantErr:  true,
+		},
+		{
+			name:     "fractional seconds",
+			duration: time.Hour + 500*time.Millisecond,
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateDuration(tt.duration)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateDuration() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidatePolicyArn(t *testing.T) {
+	tests := []struct {
+		name    string
+		arn     string
+		wantErr bool
+	}{
+		{
+			name:    "valid customer managed policy",
+			arn:     "arn:aws:iam::123456789012:policy/MyPolicy",
+			wantErr: false,
+		},
+		{
+			name:    "valid AWS managed policy",
+			arn:     "arn:aws:iam::aws:policy/ReadOnlyAccess",
+			wantErr: false,
+		},
+		{
+			name:    "valid policy with path",
+			arn:     "arn:aws-us-gov:iam::123456789012:policy/team/MyPolicy",
+			wantErr: false,
+		},
+		{
+			name:    "empty ARN",
+			arn:     "",
+			wantErr: true,
+		},
+		{
+			name:    "invalid - role instead of policy",
+			arn:     "arn:aws:iam::123456789012:role/MyRole",
+			wantErr: true,
+		},
+		{
+			name:    "invalid - missing policy name",
+			arn:     "arn:aws:iam::123456789012:policy/",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePolicyArn(tt.arn)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidatePolicyArn() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateSessionPolicies(t *testing.T) {
+	validPolicy := `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:GetObject","Resource":"*"}]}`
+	tooManyArns := make([]string, SessionPolicyARNsMax+1)
+	for i := range tooManyArns {
+		tooManyArns[i] = "arn:aws:iam::aws:policy/ReadOnlyAccess"
+	}
+
+	tests := []struct {
+		name       string
+		policy     string
+		policyArns []string
+		wantErr    bool
+	}{
+		{
+			name:    "no policies",
+			wantErr: false,
+		},
+		{
+			name:    "valid inline policy",
+			policy:  validPolicy,
+			wantErr: false,
+		},
+		{
+			name:       "valid inline and managed policies",
+			policy:     validPolicy,
+			policyArns: []string{"arn:aws:iam::aws:policy/ReadOnlyAccess"},
+			wantErr:    false,
+		},
+		{
+			name:    "invalid JSON",
+			policy:  `{"Version":`,
+			wantErr: true,
+		},
+		{
+			name:    "JSON array instead of object",
+			policy:  `[]`,
+			wantErr: true,
+		},
+		{
+			name:       "invalid policy ARN",
+			policyArns: []string{"not-an-arn"},
+			wantErr:    true,
+		},
+		{
+			name:       "too many policy ARNs",
+			policyArns: tooManyArns,
+			wantErr:    true,
+		},
+		{
+			name:    "policy too large",
+			policy:  `{"Sid":"` + strings.Repeat("a", SessionPolicyMaxLength) + `"}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateSessionPolicies(tt.policy, tt.policyArns)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateSessionPolicies() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateRoleHop(t *testing.T) {
+	tests := []struct {
+		name    string
+		hop     RoleHop
+		wantErr bool
+	}{
+		{
+			name:    "role ARN only",
+			hop:     RoleHop{RoleArn: "arn:aws:iam::123456789012:role/MyRole"},
+			wantErr: false,
+		},
+		{
+			name: "all options",
+			hop: RoleHop{
+				RoleArn:     "arn:aws:iam::123456789012:role/MyRole",
+				SessionName: "my-session@example.com",
+				ExternalID:  "external:id/123",
+				Duration:    time.Hour,
+			},
+			wantErr: false,
+		},
+		{
+			name:    "invalid role ARN",
+			hop:     RoleHop{RoleArn: "arn:aws:iam::123456789012:user/MyUser"},
+			wantErr: true,
+		},
+		{
+			name:    "session name too short",
+			hop:     RoleHop{RoleArn: "arn:aws:iam::123456789012:role/MyRole", SessionName: "a"},
+			wantErr: true,
+		},
+		{
+			name:    "session name with invalid character",
+			hop:     RoleHop{RoleArn: "arn:aws:iam::123456789012:role/MyRole", SessionName: "my session"},
+			wantErr: true,
+		},
+		{
+			name:    "external ID with invalid character",
+			hop:     RoleHop{RoleArn: "arn:aws:iam::123456789012:role/MyRole", ExternalID: "bad id"},
+			wantErr: true,
+		},
+		{
+			name:    "duration exceeds role chaining limit",
+			hop:     RoleHop{RoleArn: "arn:aws:iam::123456789012:role/MyRole", Duration: 2 * time.Hour},
+			wantErr: true,
+		},
+		{
+			name:    "duration below STS minimum",
+			hop:     RoleHop{RoleArn: "arn:aws:iam::123456789012:role/MyRole", Duration: time.Minute},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateRoleHop(tt.hop)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateRoleHop() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateOutputFormat(t *testing.T) {
+	for _, format := range []string{OutputJSON, OutputEnv, OutputFish, OutputPowerShell, OutputDotenv} {
+		if err := ValidateOutputFormat(format); err != nil {
+			t.Errorf("ValidateOutputFormat(%q) unexpected error = %v", format, err)
+		}
+	}
+	for _, format := range []string{"", "yaml", "JSON"} {
+		if err := ValidateOutputFormat(format); err == nil {
+			t.Errorf("ValidateOutputFormat(%q) expected error", format)
+		}
+	}
+}
+
+func TestValidateErrorFormat(t *testing.T) {
+	for _, format := range []string{ErrorFormatText, ErrorFormatJSON} {
+		if err := ValidateErrorFormat(format); err != nil {
+			t.Errorf("ValidateErrorFormat(%q) unexpected error = %v", format, err)
+		}
+	}
+	for _, format := range []string{"", "yaml", "JSON"} {
+		if err := ValidateErrorFormat(format); err == nil {
+			t.Errorf("ValidateErrorFormat(%q) expected error", format)
+		}
+	}
+}
+
+func TestValidateProvider(t *testing.T) {
+	for _, provider := range []string{ProviderAuto, ProviderGCP, ProviderFile, ProviderGitHub, ProviderGitLab, ProviderAzure} {
+		if err := ValidateProvider(provider); err != nil {
+			t.Errorf("ValidateProvider(%q) unexpected error = %v", provider, err)
+		}
+	}
+	for _, provider := range []string{"", "aws", "GitHub"} {
+		if err := ValidateProvider(provider); err == nil {
+			t.Errorf("ValidateProvider(%q) expected error", provider)
+		}
+	}
+}
+
+func TestValidateProfileName(t *testing.T) {
+	for _, name := range []string{"default", "janus", "my-profile.prod"} {
+		if err := ValidateProfileName(name); err != nil {
+			t.Errorf("ValidateProfileName(%q) unexpected error = %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "bad]name", "[bad", "two\nlines", " padded "} {
+		if err := ValidateProfileName(name); err == nil {
+			t.Errorf("ValidateProfileName(%q) expected error", name)
+		}
+	}
+}
+
+func TestValidateServiceAccountEmail(t *testing.T) {
+	for _, email := range []string{"aws-access@my-project.iam.gserviceaccount.com", "1234567890-compute@developer.gserviceaccount.com"} {
+		if err := ValidateServiceAccountEmail(email); err != nil {
+			t.Errorf("ValidateServiceAccountEmail(%q) unexpected error = %v", email, err)
+		}
+	}
+	for _, email := range []string{"", "aws-access", "@my-project.iam.gserviceaccount.com", "a@b@c.com", "projects/-/serviceAccounts/a@b.com"} {
+		if err := ValidateServiceAccountEmail(email); err == nil {
+			t.Errorf("ValidateServiceAccountEmail(%q) expected error", email)
+		}
+	}
+}
+
+func TestValidateRetries(t *testing.T) {
+	tests := []struct {
+		name    string
+		retries int
+		delay   time.Duration
+		wantErr bool
+	}{
+		{"no retries", 0, 0, false},
+		{"defaults", RetriesDefault, RetryDelayDefault, false},
+		{"maximum", RetriesMax, time.Second, false},
+		{"negative retries", -1, RetryDelayDefault, true},
+		{"too many retries", RetriesMax + 1, RetryDelayDefault, true},
+		{"negative delay", RetriesDefault, -time.Second, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateRetries(tt.retries, tt.delay)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateRetries() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateSessionTags(t *testing.T) {
+	tests := []struct {
+		name           string
+		tags           []SessionTag
+		metadataTags   []MetadataTag
+		transitiveKeys []string
+		wantErr        bool
+	}{
+		{
+			name: "no tags",
+		},
+		{
+			name:           "static and metadata tags",
+			tags:           []SessionTag{{Key: "team", Value: "platform"}, {Key: "cost-center", Value: ""}},
+			metadataTags:   []MetadataTag{{Key: "project", Source: TagSourceProjectID}, {Key: "namespace", Source: TagSourceNamespace}},
+			transitiveKeys: []string{"Team", "project"},
+		},
+		{
+			name: "value with allowed punctuation",
+			tags: []SessionTag{{Key: "owner", Value: "ops@example.com:/team=a+b"}},
+		},
+		{
+			name:    "empty key",
+			tags:    []SessionTag{{Key: "", Value: "x"}},
+			wantErr: true,
+		},
+		{
+			name:    "reserved key prefix",
+			tags:    []SessionTag{{Key: "AWS:team", Value: "x"}},
+			wantErr: true,
+		},
+		{
+			name:    "key too long",
+			tags:    []SessionTag{{Key: strings.Repeat("k", SessionTagKeyMaxLen+1), Value: "x"}},
+			wantErr: true,
+		},
+		{
+			name:    "value too long",
+			tags:    []SessionTag{{Key: "team", Value: strings.Repeat("v", SessionTagValueMaxLen+1)}},
+			wantErr: true,
+		},
+		{
+			name:    "value with invalid character",
+			tags:    []SessionTag{{Key: "team", Value: "a,b"}},
+			wantErr: true,
+		},
+		{
+			name:         "duplicate key ignoring case",
+			tags:         []SessionTag{{Key: "Project", Value: "x"}},
+			metadataTags: []MetadataTag{{Key: "project", Source: TagSourceProjectID}},
+			wantErr:      true,
+		},
+		{
+			name:         "unknown metadata source",
+			metadataTags: []MetadataTag{{Key: "region", Source: "region"}},
+			wantErr:      true,
+		},
+		{
+			name:           "transitive key without tag",
+			tags:           []SessionTag{{Key: "team", Value: "platform"}},
+			transitiveKeys: []string{"project"},
+			wantErr:        true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateSessionTags(tt.tags, tt.metadataTags, tt.transitiveKeys)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateSessionTags() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	tooMany := make([]SessionTag, SessionTagsMax+1)
+	for i := range tooMany {
+		tooMany[i] = SessionTag{Key: fmt.Sprintf("tag%d", i), Value: "x"}
+	}
+	if err := ValidateSessionTags(tooMany, nil, nil); err == nil {
+		t.Errorf("ValidateSessionTags() expected error for %d tags", len(tooMany))
+	}
+}
+
+func TestValidateSourceIdentity(t *testing.T) {
+	for _, sourceIdentity := range []string{"aws-access@my-project.iam.gserviceaccount.com", "my-project_host-1", "ab"} {
+		if err := ValidateSourceIdentity(sourceIdentity); err != nil {
+			t.Errorf("ValidateSourceIdentity(%q) unexpected error = %v", sourceIdentity, err)
+		}
+	}
+	for _, sourceIdentity := range []string{"", "a", "aws:user", "two words", "project/host", strings.Repeat("a", 65)} {
+		if err := ValidateSourceIdentity(sourceIdentity); err == nil {
+			t.Errorf("ValidateSourceIdentity(%q) expected error", sourceIdentity)
+		}
+	}
+}
+
+func TestValidateSTSEndpoint(t *testing.T) {
+	for _, endpoint := range []string{"https://sts.eu-west-1.amazonaws.com", "https://vpce-0123-abcd.sts.us-east-1.vpce.amazonaws.com", "http://127.0.0.1:4566"} {
+		if err := ValidateSTSEndpoint(endpoint); err != nil {
+			t.Errorf("ValidateSTSEndpoint(%q) unexpected error = %v", endpoint, err)
+		}
+	}
+	for _, endpoint := range []string{"", "sts.eu-west-1.amazonaws.com", "ftp://sts.amazonaws.com", "https://", "https://sts.amazonaws.com?x=1"} {
+		if err := ValidateSTSEndpoint(endpoint); err == nil {
+			t.Errorf("ValidateSTSEndpoint(%q) expected error", endpoint)
+		}
+	}
+}
+
+func TestValidateSTSFIPSRegion(t *testing.T) {
+	for _, region := range []string{"us-east-1", "us-west-2", "us-gov-west-1", "US-EAST-2"} {
+		if err := ValidateSTSFIPSRegion(region); err != nil {
+			t.Errorf("ValidateSTSFIPSRegion(%q) unexpected error = %v", region, err)
+		}
+	}
+	for _, region := range []string{"", "eu-west-1", "ca-central-1", "cn-north-1"} {
+		if err := ValidateSTSFIPSRegion(region); err == nil {
+			t.Errorf("ValidateSTSFIPSRegion(%q) expected error", region)
+		}
+	}
+}
+
+func TestValidateSTSPartition(t *testing.T) {
+	const (
+		commercialRole = "arn:aws:iam::123456789012:role/MyRole"
+		chinaRole      = "arn:aws-cn:iam::123456789012:role/MyRole"
+		govCloudRole   = "arn:aws-us-gov:iam::123456789012:role/MyRole"
+	)
+
+	tests := []struct {
+		name     string
+		roleArn  string
+		region   string
+		endpoint string
+		wantErr  bool
+	}{
+		{"commercial region", commercialRole, "eu-west-1", "", false},
+		{"China region", chinaRole, "cn-north-1", "", false},
+		{"GovCloud region", govCloudRole, "us-gov-west-1", "", false},
+		{"commercial role in China region", commercialRole, "cn-north-1", "", true},
+		{"China role in commercial region", chinaRole, "us-east-1", "", true},
+		{"regional endpoint", commercialRole, "us-east-1", "https://sts.eu-west-1.amazonaws.com", false},
+		{"FIPS endpoint", commercialRole, "us-east-1", "https://sts-fips.us-east-2.amazonaws.com", false},
+		{"dual-stack endpoint", commercialRole, "us-east-1", "https://sts.us-east-1.api.aws", false},
+		{"VPC endpoint", commercialRole, "us-east-1", "https://vpce-0123-abcd.sts.us-east-1.vpce.amazonaws.com", false},
+		{"China endpoint", chinaRole, "cn-north-1", "https://sts.cn-north-1.amazonaws.com.cn", false},
+		{"GovCloud endpoint", govCloudRole, "us-gov-west-1", "https://sts.us-gov-west-1.amazonaws.com", false},
+		{"local stand-in", chinaRole, "us-east-1", "http://127.0.0.1:4566", false},
+		{"commercial role at China endpoint", commercialRole, "us-east-1", "https://sts.cn-north-1.amazonaws.com.cn", true},
+		{"commercial role at GovCloud endpoint", commercialRole, "us-east-1", "https://sts.us-gov-east-1.amazonaws.com", true},
+		{"GovCloud role at commercial endpoint", govCloudRole, "us-gov-west-1", "https://sts.us-east-1.amazonaws.com", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateSTSPartition(tt.roleArn, tt.region, tt.endpoint)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateSTSPartition() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}